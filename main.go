@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -9,6 +12,8 @@ import (
 	"io"
 
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/alistanis/silentinstall/silent"
 	"github.com/alistanis/silentinstall/silent/ui"
@@ -16,15 +21,101 @@ import (
 
 // setup const messages
 const (
-	configVarMsg = "The path of the config file"
-	verboseMsg   = "Prints verbose output if true"
+	configVarMsg     = "The path of the config file"
+	verboseMsg       = "Prints verbose output if true"
+	parallelMsg      = "The maximum number of commands to run at the same time"
+	failurePolicyMsg = "What to do when a command fails: fail-fast or finish-independent"
+	stateFileMsg     = "The path of the file recording completed commands, only kept with -resume or -state-file (default: the config file path + .state)"
+	resumeMsg        = "Skips commands that completed in a previous run and haven't changed since"
+	fromMsg          = "Skips every command declared before the named command"
+	onlyMsg          = "Runs only the named command, may be repeated"
+	skipMsg          = "Skips the named command, may be repeated"
+	logLevelMsg      = "The least important log entries written: trace, debug, info, warn or error (default: warn, or trace with -v)"
+	logFileMsg       = "The path of the file log entries are appended to (default: stderr)"
+	logFormatMsg     = "How log entries are written: text or json"
+	uiMsg            = "How progress is shown: colored, plain, machine or json"
+	colorMsg         = "Whether the colored ui uses colors: auto, always or never"
+	uiSinkMsg        = "Also shows progress on another ui, as KIND[:PATH][@LEVEL], e.g. plain:install.log@say, may be repeated"
+	eventsMsg        = "The path of a file to write JSON Lines events to as the run progresses, - for stdout (the ui then uses stderr)"
+	varMsg           = "Sets a variable used by the config, as NAME=value, may be repeated"
+	noPromptMsg      = "Never asks for missing variables, failing instead, even when run from a terminal"
+	reportMsg        = "The path of a file to write a JSON report of the run to once it has finished"
+	junitMsg         = "The path of a file to write a JUnit XML report of the run to once it has finished"
+	junitExpectMsg   = "Also makes each expectation a testcase in the JUnit report, failing if it was never matched"
+	htmlReportMsg    = "The path of a file to write a self-contained HTML report of the run to once it has finished"
+	traceMsg         = "The path of a file to write a Chrome trace of the run to once it has finished, for chrome://tracing or Perfetto"
+	statusMsg        = "Shows a live status line for each running command instead of its output (unless -v), when the ui is colored or plain and on a terminal"
 )
 
 var (
-	configFile = flag.String("f", "", configVarMsg)
-	coloredUi  = ui.NewColoredUi()
+	configFile    = flag.String("f", "", configVarMsg)
+	parallel      = flag.Int("parallel", 1, parallelMsg)
+	failurePolicy = flag.String("failure-policy", "fail-fast", failurePolicyMsg)
+	stateFile     = flag.String("state-file", "", stateFileMsg)
+	resume        = flag.Bool("resume", false, resumeMsg)
+	from          = flag.String("from", "", fromMsg)
+	verbose       = flag.Bool("v", false, verboseMsg)
+	logLevel      = flag.String("log-level", "", logLevelMsg)
+	logFile       = flag.String("log-file", "", logFileMsg)
+	logFormat     = flag.String("log-format", "text", logFormatMsg)
+	uiKind        = flag.String("ui", ui.KindColored, uiMsg)
+	colorMode     = flag.String("color", "auto", colorMsg)
+	eventsFile    = flag.String("events", "", eventsMsg)
+	noPrompt      = flag.Bool("no-prompt", false, noPromptMsg)
+	status        = flag.Bool("status", false, statusMsg)
+	reportFile    = flag.String("report", "", reportMsg)
+	junitFile     = flag.String("junit", "", junitMsg)
+	junitExpect   = flag.Bool("junit-expectations", false, junitExpectMsg)
+	htmlReport    = flag.String("html-report", "", htmlReportMsg)
+	traceFile     = flag.String("trace", "", traceMsg)
+	vars          = varMap{}
+	only          stringList
+	skip          stringList
+	uiSinks       stringList
+	// out shows progress on the terminal, leaving stderr's log entries to the logger. It's replaced by
+	// the ui chosen with -ui once the flags have been parsed
+	out ui.Ui = &ui.ColoredUi{
+		Color:      ui.UiColorGreen,
+		ErrorColor: ui.UiColorRed,
+		Ui:         &ui.BasicUi{Reader: os.Stdin, Writer: os.Stdout, ErrorWriter: os.Stderr},
+	}
+	// console is the file out shows progress on, and extraSinks are the other uis it shows progress on
+	console    = os.Stdout
+	extraSinks []*ui.Sink
 )
 
+// stringList is a flag.Value collecting every value of a repeated flag, values may also be comma separated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// varMap is a flag.Value collecting the NAME=value pairs of a repeated flag
+type varMap map[string]string
+
+func (m varMap) String() string {
+	var pairs []string
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m varMap) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("%q must be NAME=value", value)
+	}
+	m[kv[0]] = kv[1]
+	return nil
+}
+
 const (
 	_ = iota // skip 0
 	// starting at -1, decrement for each additional value
@@ -32,49 +123,275 @@ const (
 	exitBadFile
 	exitBadConfig
 	exitCmdError
+	exitBadFlags
 )
 
 // set our flagvars
 func init() {
 	flag.StringVar(configFile, "file", "", configVarMsg)
-	flag.BoolVar(&silent.Verbose, "v", false, verboseMsg)
+	flag.Var(&only, "only", onlyMsg)
+	flag.Var(&skip, "skip", skipMsg)
+	flag.Var(&uiSinks, "ui-sink", uiSinkMsg)
+	flag.Var(vars, "var", varMsg)
 }
 
 // parse those flags
-func parseFlags() {
+func parseFlags() (policy silent.FailurePolicy) {
 	flag.Parse()
-	if silent.Verbose {
-		log.SetFlags(log.Lshortfile | log.LstdFlags)
+	chosen, err := newUi()
+	if err != nil {
+		out.Error(err.Error())
+		os.Exit(exitBadFlags)
 	}
+	out = withSinks(chosen)
 	if *configFile == "" {
-		coloredUi.Err("Must provide -f or --file for the path of the config file to use.")
+		out.Error("Must provide -f or --file for the path of the config file to use.")
 		os.Exit(exitNoFileProvided)
 	}
+	policy, err = silent.ParseFailurePolicy(*failurePolicy)
+	if err != nil {
+		out.Error(err.Error())
+		os.Exit(exitBadFlags)
+	}
+	return policy
+}
+
+// newUi returns the ui described by the -ui and -color flags, setting console and adding the sinks described
+// by -ui-sink to extraSinks
+func newUi() (ui.Ui, error) {
+	color, err := ui.ParseColorMode(*colorMode)
+	if err != nil {
+		return nil, err
+	}
+	if *eventsFile == "-" {
+		// stdout is reserved for events
+		console = os.Stderr
+	}
+	chosen, err := ui.New(*uiKind, color, os.Stdin, console, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range uiSinks {
+		kind, path, level, err := ui.ParseSinkSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		var w io.Writer = console
+		if path != "" && path != "-" {
+			// like the log file, this is left for the os to close when we exit
+			if w, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+				return nil, err
+			}
+		}
+		sink, err := ui.New(kind, color, nil, w, w)
+		if err != nil {
+			return nil, err
+		}
+		extraSinks = append(extraSinks, &ui.Sink{Ui: sink, Level: level})
+	}
+	return chosen, nil
+}
+
+// withSinks returns u, also showing progress on any extra sinks
+func withSinks(u ui.Ui) ui.Ui {
+	if len(extraSinks) == 0 {
+		return u
+	}
+	return &ui.MultiUi{Sinks: append([]*ui.Sink{{Ui: u, Interactive: true}}, extraSinks...)}
+}
+
+// statusDisplay returns the status display asked for with -status, or nil if there isn't one because the ui
+// isn't colored or plain, or the console isn't a terminal
+func statusDisplay() *silent.StatusDisplay {
+	if !*status || (*uiKind != ui.KindColored && *uiKind != ui.KindPlain) || !ui.IsTerminal(console) {
+		return nil
+	}
+	display := silent.NewStatusDisplay(console, nil)
+	display.Verbose = *verbose
+	display.Width = ui.TerminalWidth(console)
+	return display
+}
+
+// newLogger returns the logger described by the logging flags, writing to stderr unless there's a log file.
+// Anything else using the standard logger, such as the ui, is sent to it at trace level
+func newLogger(stderr io.Writer) (*silent.Logger, error) {
+	level := silent.LevelWarn
+	if *verbose {
+		level = silent.LevelTrace
+	}
+	if *logLevel != "" {
+		var err error
+		if level, err = silent.ParseLevel(*logLevel); err != nil {
+			return nil, err
+		}
+	}
+	format, err := silent.ParseLogFormat(*logFormat)
+	if err != nil {
+		return nil, err
+	}
+	w := stderr
+	if *logFile != "" {
+		// the file is left for the os to close when we exit
+		if w, err = os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return nil, err
+		}
+	}
+	logger := silent.NewLogger(w, level, format)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(silent.LevelTrace))
+	return logger, nil
+}
+
+// eventSinks returns the event sinks described by the -events flag
+func eventSinks() ([]silent.RunOption, error) {
+	switch *eventsFile {
+	case "":
+		return nil, nil
+	case "-":
+		return []silent.RunOption{silent.WithEventSink(silent.NewJSONLSink(os.Stdout))}, nil
+	}
+	// like the log file, this is left for the os to close when we exit
+	f, err := os.OpenFile(*eventsFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return []silent.RunOption{silent.WithEventSink(silent.NewJSONLSink(f))}, nil
+}
+
+// writeReports writes the report files asked for with -report, -junit, -html-report and -trace, named after the config
+// file. events are the events recorded during the run
+func writeReports(report *silent.Report, name string, events []*silent.Event) error {
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(*reportFile, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	if *junitFile != "" {
+		var b bytes.Buffer
+		if err := report.WriteJUnit(&b, name, *junitExpect); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*junitFile, b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	if *htmlReport != "" {
+		var b bytes.Buffer
+		if err := report.WriteHTML(&b, name, events); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*htmlReport, b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	if *traceFile != "" {
+		var b bytes.Buffer
+		if err := silent.WriteTrace(&b, events); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*traceFile, b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOptions returns the options the config is loaded with. Missing variables are only asked for on out when stdin
+// is a terminal, so that runs from CI, services or nohup fail listing every missing variable instead
+func loadOptions(stdin *os.File, out ui.Ui) []silent.LoadOption {
+	opts := []silent.LoadOption{silent.WithVars(vars)}
+	if !*noPrompt && ui.IsTerminal(stdin) {
+		opts = append(opts, silent.WithPrompt(out))
+	}
+	return opts
 }
 
 func main() {
-	parseFlags()
+	policy := parseFlags()
+	var stderr io.Writer = os.Stderr
+	display := statusDisplay()
+	if display != nil && ui.IsTerminal(os.Stderr) {
+		// log entries are shown above the display's status lines, rather than over them
+		stderr = display
+	}
+	logger, err := newLogger(stderr)
+	if err != nil {
+		out.Error(err.Error())
+		os.Exit(exitBadFlags)
+	}
 
 	file := filepath.Clean(*configFile)
 	// read config data
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		coloredUi.Err(err)
+		out.Error(err.Error())
 		os.Exit(exitBadFile)
 	}
 
-	// convert json to list of commands
-	cmds, err := silent.NewSilentCmdsFromJSON(data)
+	// convert json to a config, asking for missing variables if someone's there to answer
+	cfg, err := silent.NewConfigFromJSON(data, loadOptions(os.Stdin, out)...)
 	if err != nil {
-		coloredUi.Err(err)
+		out.Error(err.Error())
 		os.Exit(exitBadConfig)
 	}
+	// find out what's already been done, if anyone asked
+	var state *silent.State
+	if *resume || *stateFile != "" {
+		if *stateFile == "" {
+			*stateFile = file + ".state"
+		}
+		if state, err = silent.LoadState(*stateFile); err != nil {
+			out.Error(err.Error())
+			os.Exit(exitBadFile)
+		}
+	}
+
 	// execute them!
-	err = cmds.Exec()
+	runner := cfg.Runner()
+	runner.Parallel = *parallel
+	runner.Policy = policy
+	runner.State = state
+	runner.Resume = *resume
+	runner.From = *from
+	runner.Only = only
+	runner.Skip = skip
+	sinks, err := eventSinks()
+	if err != nil {
+		out.Error(err.Error())
+		os.Exit(exitBadFile)
+	}
+	// reports built after the run need its events
+	recorder := &silent.EventRecorder{}
+	if *htmlReport != "" || *traceFile != "" {
+		sinks = append(sinks, silent.WithEventSink(recorder))
+	}
+	runUi := out
+	if display != nil {
+		// the display takes the console's place, leaving the extra sinks to show everything
+		runUi = withSinks(&ui.BasicUi{Writer: ioutil.Discard, ErrorWriter: ioutil.Discard})
+		sinks = append(sinks, silent.WithEventSink(display))
+		display.Start(100 * time.Millisecond)
+	}
+	err = runner.Run(append(sinks, silent.WithLogger(logger), silent.WithUi(runUi))...)
+	if display != nil {
+		display.Stop()
+	}
+	// summarize them!
+	report := runner.Report()
+	out.Say(report.Table())
+	if reportErr := writeReports(report, filepath.Base(file), recorder.Events()); reportErr != nil {
+		out.Error(reportErr.Error())
+		os.Exit(exitBadFile)
+	}
 	if err == io.EOF {
-		coloredUi.Say("SilentInstall has finished successfully!")
+		out.Say("SilentInstall has finished successfully!")
 	} else {
-		coloredUi.Err(err)
+		out.Error(err.Error())
 		os.Exit(exitCmdError)
 	}
 }
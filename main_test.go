@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/alistanis/silentinstall/silent"
+	"github.com/alistanis/silentinstall/silent/ui"
+)
+
+func TestLoadOptions_notTerminal(t *testing.T) {
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	out := &bytes.Buffer{}
+	_, err = silent.NewConfigFromJSON([]byte(`{
+		"vars": {"DB_PASSWORD": {"secret": true, "description": "db password"}, "NAME": {"prompt": true}},
+		"commands": [{"cmd": "echo {{.NAME}} {{.DB_PASSWORD}}"}]
+	}`), loadOptions(stdin, &ui.BasicUi{Reader: stdin, Writer: out, ErrorWriter: out})...)
+	expected := "missing values for variables: DB_PASSWORD (db password), NAME"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+	if out.Len() > 0 {
+		t.Fatalf("nothing should be asked: %q", out.String())
+	}
+}
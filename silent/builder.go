@@ -0,0 +1,292 @@
+package silent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Builder builds a SilentCmd one call at a time, for Go programs that would otherwise have to generate JSON:
+//
+//	cmd, err := silent.Command("./installer").Args("--prefix", "/opt/app").
+//		Expect("Name?").Respond("Chris").
+//		ExpectRegex(`[Pp]assword:`).RespondSecret(password).
+//		Timeout(5 * time.Minute).
+//		Build()
+//
+// Mistakes are remembered rather than reported by each call, and the first one is returned by Build.
+type Builder struct {
+	path    string
+	args    []string
+	env     []string
+	dir     string
+	connect string
+	// def holds everything else that ends up in the built command
+	def SilentCmd
+	// responded is true once the last expectation has been given a response
+	responded bool
+	err       error
+}
+
+// Command starts building a command that runs the program at path, which is looked up in PATH if it has no slashes
+func Command(path string) *Builder {
+	b := &Builder{path: path}
+	if path == "" {
+		b.fail(errors.New("command path must not be empty"))
+	}
+	return b
+}
+
+// Connect starts building a command that talks to target instead of running a program, see NewConnectTransport
+func Connect(target string) *Builder {
+	b := &Builder{connect: target}
+	if target == "" {
+		b.fail(errors.New("connect target must not be empty"))
+	}
+	return b
+}
+
+// fail records err, unless an earlier mistake has already been recorded
+func (b *Builder) fail(err error) *Builder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// Name sets the name other commands use to depend on this one
+func (b *Builder) Name(name string) *Builder {
+	b.def.Name = name
+	return b
+}
+
+// Args appends arguments to the program's command line
+func (b *Builder) Args(args ...string) *Builder {
+	if b.connect != "" {
+		return b.fail(errors.New("a connect target takes no arguments"))
+	}
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Env adds "KEY=value" entries to the program's environment, which otherwise is the current process's environment
+func (b *Builder) Env(env ...string) *Builder {
+	for _, e := range env {
+		if !strings.Contains(e, "=") {
+			return b.fail(fmt.Errorf("environment entry %q must be of the form KEY=value", e))
+		}
+	}
+	b.env = append(b.env, env...)
+	return b
+}
+
+// Dir sets the program's working directory
+func (b *Builder) Dir(dir string) *Builder {
+	b.dir = dir
+	return b
+}
+
+// Pty runs the program attached to a pseudo terminal rather than pipes
+func (b *Builder) Pty() *Builder {
+	b.def.Pty = true
+	return b
+}
+
+// Timeout limits how long each execution of the command may take
+func (b *Builder) Timeout(d time.Duration) *Builder {
+	if d < 0 {
+		return b.fail(fmt.Errorf("timeout %s must not be negative", d))
+	}
+	b.def.Timeout = Duration{d}
+	return b
+}
+
+// DependsOn adds the names of commands that must finish successfully before this one is started
+func (b *Builder) DependsOn(names ...string) *Builder {
+	b.def.DependsOn = append(b.def.DependsOn, names...)
+	return b
+}
+
+// Expect adds an expectation for text in the command's output. Unless followed by Respond or RespondSecret,
+// a bare newline is sent when it is matched
+func (b *Builder) Expect(text string) *Builder {
+	return b.expect(&Expectation{Input: text})
+}
+
+// ExpectRegex adds an expectation for a regular expression in the command's output, see Expect
+func (b *Builder) ExpectRegex(pattern string) *Builder {
+	e := &Expectation{Input: pattern, Regexp: true}
+	if err := e.Compile(); err != nil {
+		return b.fail(err)
+	}
+	return b.expect(e)
+}
+
+func (b *Builder) expect(e *Expectation) *Builder {
+	b.def.Expectations = append(b.def.Expectations, e)
+	b.responded = false
+	return b
+}
+
+// Respond sets what is sent when the last expectation is matched
+func (b *Builder) Respond(output string) *Builder {
+	return b.respond(output, false)
+}
+
+// RespondSecret is like Respond, but keeps output out of logs and transcripts
+func (b *Builder) RespondSecret(output string) *Builder {
+	return b.respond(output, true)
+}
+
+func (b *Builder) respond(output string, secret bool) *Builder {
+	if len(b.def.Expectations) == 0 {
+		return b.fail(fmt.Errorf("response %q has no expectation to respond to", output))
+	}
+	if b.responded {
+		return b.fail(errors.New("an expectation can only have one response"))
+	}
+	e := b.def.Expectations[len(b.def.Expectations)-1]
+	e.Output = output
+	e.Secret = secret
+	b.responded = true
+	return b
+}
+
+// Retries sets how many times the command is run again after failing, waiting delay before the first retry
+// and multiplying the delay by backoff after each one when backoff is greater than 1
+func (b *Builder) Retries(n int, delay time.Duration, backoff float64) *Builder {
+	if n < 0 || delay < 0 || backoff < 0 {
+		return b.fail(errors.New("retries, retry delay and backoff must not be negative"))
+	}
+	b.def.Retries = n
+	b.def.RetryDelay = Duration{delay}
+	b.def.Backoff = backoff
+	return b
+}
+
+// RetryOnExitCodes limits retries to failures with any of the given exit codes, or output matched by RetryOnOutput
+func (b *Builder) RetryOnExitCodes(codes ...int) *Builder {
+	if b.def.RetryOn == nil {
+		b.def.RetryOn = &RetryOn{}
+	}
+	b.def.RetryOn.ExitCodes = append(b.def.RetryOn.ExitCodes, codes...)
+	return b
+}
+
+// RetryOnOutput limits retries to failures whose output matches any of the given regular expressions, or that
+// exited with a code given to RetryOnExitCodes
+func (b *Builder) RetryOnOutput(patterns ...string) *Builder {
+	if b.def.RetryOn == nil {
+		b.def.RetryOn = &RetryOn{}
+	}
+	b.def.RetryOn.Output = append(b.def.RetryOn.Output, patterns...)
+	if err := b.def.RetryOn.Compile(); err != nil {
+		return b.fail(err)
+	}
+	return b
+}
+
+// IgnoreErrors lets the run, and the command's dependents, carry on even if the command fails
+func (b *Builder) IgnoreErrors() *Builder {
+	b.def.IgnoreErrors = true
+	return b
+}
+
+// Creates skips the command if path already exists
+func (b *Builder) Creates(path string) *Builder {
+	b.def.Creates = path
+	return b
+}
+
+// Removes skips the command if path does not exist
+func (b *Builder) Removes(path string) *Builder {
+	b.def.Removes = path
+	return b
+}
+
+// OnlyIf skips the command unless the check command exits successfully
+func (b *Builder) OnlyIf(check string) *Builder {
+	b.def.OnlyIf = check
+	return b
+}
+
+// Unless skips the command if the check command exits successfully
+func (b *Builder) Unless(check string) *Builder {
+	b.def.Unless = check
+	return b
+}
+
+// OnFailure adds hooks run after the command has failed and has no retries left
+func (b *Builder) OnFailure(hooks ...*SilentCmd) *Builder {
+	b.def.OnFailure = append(b.def.OnFailure, hooks...)
+	return b
+}
+
+// OnSuccess adds hooks run after the command has succeeded
+func (b *Builder) OnSuccess(hooks ...*SilentCmd) *Builder {
+	b.def.OnSuccess = append(b.def.OnSuccess, hooks...)
+	return b
+}
+
+// Always adds hooks run after the command has either succeeded or failed
+func (b *Builder) Always(hooks ...*SilentCmd) *Builder {
+	b.def.Always = append(b.def.Always, hooks...)
+	return b
+}
+
+// Build returns the command, initialized just like one loaded by NewConfigFromJSON, or the first mistake made
+// while building it. A Builder may be built more than once, each time returning a new command
+func (b *Builder) Build() (*SilentCmd, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	for _, hooks := range []SilentCmds{b.def.OnFailure, b.def.OnSuccess, b.def.Always} {
+		for _, h := range hooks {
+			if h == nil {
+				return nil, errors.New("hooks must not be nil")
+			}
+		}
+	}
+
+	s := new(SilentCmd)
+	*s = b.def
+	s.Init()
+	s.DependsOn = append([]string(nil), b.def.DependsOn...)
+	s.OnFailure = append(SilentCmds(nil), b.def.OnFailure...)
+	s.OnSuccess = append(SilentCmds(nil), b.def.OnSuccess...)
+	s.Always = append(SilentCmds(nil), b.def.Always...)
+	s.Expectations = nil
+	for _, e := range b.def.Expectations {
+		copied := *e
+		s.Expectations = append(s.Expectations, &copied)
+	}
+	if b.def.RetryOn != nil {
+		retryOn := *b.def.RetryOn
+		s.RetryOn = &retryOn
+	}
+
+	if b.connect != "" {
+		if _, err := NewConnectTransport(b.connect, s.Timeout.Duration); err != nil {
+			return nil, err
+		}
+		s.Connect = b.connect
+		return s, nil
+	}
+	s.Cmd = exec.Command(b.path, b.args...)
+	s.Cmd.Env = append(os.Environ(), b.env...)
+	s.Cmd.Dir = b.dir
+	s.CmdString = strings.Join(append([]string{b.path}, b.args...), " ")
+	return s, nil
+}
+
+// MustBuild is like Build, but panics on a mistake. It's meant for commands built from constants
+func (b *Builder) MustBuild() *SilentCmd {
+	s, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package silent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuilder(t *testing.T) {
+	Convey("Built commands run just like ones loaded from JSON", t, func() {
+		dir, err := ioutil.TempDir("", "silent-builder")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		s, err := Command("sh").Args("-c", `printf 'Name? '; read name; printf 'Password: '; read p; echo "$GREETING $name $p $(pwd)"`).
+			Name("greet").
+			Env("GREETING=hello").
+			Dir(dir).
+			Expect("Name?").Respond("Chris").
+			ExpectRegex(`[Pp]assword:`).RespondSecret("hunter2").
+			Timeout(5 * time.Second).
+			Build()
+		So(err, ShouldBeNil)
+		So(s.DisplayName(), ShouldEqual, "greet")
+		So(s.Expectations[1].Secret, ShouldBeTrue)
+		So(s.Timeout.Duration, ShouldEqual, 5*time.Second)
+
+		result := s.Run()
+		So(result.Err, ShouldBeNil)
+		So(result.Status, ShouldEqual, StatusOK)
+		real, _ := filepath.EvalSymlinks(dir)
+		So(s.Output(), ShouldEqual, "Name? Password: hello Chris hunter2 "+real+"\n")
+	})
+
+	Convey("Expectations without a response send a bare newline", t, func() {
+		s, err := Command("sh").Args("-c", "printf 'Press RETURN'; read x; echo done").Expect("RETURN").Build()
+		So(err, ShouldBeNil)
+		So(s.Exec(), ShouldEqual, io.EOF)
+		So(s.Output(), ShouldEqual, "Press RETURNdone\n")
+	})
+
+	Convey("Each Build returns a separate command", t, func() {
+		b := Command("echo").Args("hi").Expect("x").Respond("y").DependsOn("a")
+		first, second := b.MustBuild(), b.MustBuild()
+		So(first, ShouldNotPointTo, second)
+		So(first.Expectations[0], ShouldNotPointTo, second.Expectations[0])
+		first.DependsOn[0] = "b"
+		So(second.DependsOn, ShouldResemble, []string{"a"})
+		So(first.CmdString, ShouldEqual, "echo hi")
+	})
+
+	Convey("Built commands retry, guard and hook like loaded ones", t, func() {
+		dir, err := ioutil.TempDir("", "silent-builder")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		record := filepath.Join(dir, "record")
+
+		s, err := Command("sh").Args("-c", "echo flaky; exit 7").
+			Retries(2, time.Millisecond, 2).
+			RetryOnExitCodes(7).
+			IgnoreErrors().
+			Unless("test -e " + filepath.Join(dir, "never")).
+			OnFailure(recordCmd(record, "failed")).
+			Build()
+		So(err, ShouldBeNil)
+		So(s.RetryOn.Matches(7, ""), ShouldBeTrue)
+		So(s.RetryOn.Matches(1, ""), ShouldBeFalse)
+		result := s.Run()
+		So(result.Status, ShouldEqual, StatusIgnored)
+		So(result.Attempts, ShouldEqual, 3)
+		So(readRecord(record), ShouldResemble, []string{"failed"})
+	})
+
+	Convey("Connect targets are validated when built", t, func() {
+		s, err := Connect("tcp://127.0.0.1:1").Timeout(time.Second).Build()
+		So(err, ShouldBeNil)
+		So(s.Cmd, ShouldBeNil)
+		So(s.DisplayName(), ShouldEqual, "tcp://127.0.0.1:1")
+
+		_, err = Connect("gopher://example.com").Build()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Mistakes are reported by Build", t, func() {
+		mistakes := map[string]*Builder{
+			"empty path":              Command(""),
+			"empty target":            Connect(""),
+			"connect arguments":       Connect("tcp://127.0.0.1:1").Args("x"),
+			"bad environment":         Command("true").Env("NOPE"),
+			"negative timeout":        Command("true").Timeout(-time.Second),
+			"response without expect": Command("true").Respond("x"),
+			"two responses":           Command("true").Expect("a").Respond("x").Respond("y"),
+			"invalid pattern":         Command("true").ExpectRegex("("),
+			"invalid retry pattern":   Command("true").RetryOnOutput("["),
+			"negative retries":        Command("true").Retries(-1, 0, 0),
+			"nil hook":                Command("true").Always(nil),
+		}
+		for mistake, b := range mistakes {
+			b := b
+			Convey(mistake, func() {
+				_, err := b.Build()
+				So(err, ShouldNotBeNil)
+				So(func() { b.MustBuild() }, ShouldPanic)
+			})
+		}
+	})
+}
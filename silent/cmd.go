@@ -2,40 +2,99 @@ package silent
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/alistanis/silentinstall/silent/ui"
 )
 
-var (
-	Verbose bool
-)
-
 // SilentCmd is a command that will run silently
 // this can be a regular command or it can be one that expects input from the user
 type SilentCmd struct {
-	Cmd           *exec.Cmd
-	CmdString     string         `json:"cmd"`
-	Expectations  []*Expectation `json:"expectations"`
-	ReceiveBuffer *bytes.Buffer
-	ReadChan      chan string
-	ErrChan       chan error
-	ErrStringChan chan string
-	coloredUI     ui.Ui
+	Cmd *exec.Cmd `json:"-"`
+	// Transport, if set, is used instead of running Cmd. A Transport is started once per execution, so one that
+	// can't be restarted must be replaced before the command is run again, e.g. for a retry
+	Transport Transport `json:"-"`
+	// Pty runs Cmd attached to a pseudo terminal rather than pipes
+	Pty bool `json:"pty"`
+	// Connect talks to a target such as "tcp://host:port", "telnet://host:port", "unix:///path/to/socket"
+	// or "fifo:///path/to/output?in=/path/to/input" instead of running Cmd, see NewConnectTransport
+	Connect string `json:"connect"`
+	// Timeout limits how long each execution of this command may take, including connecting to its target
+	Timeout Duration `json:"timeout"`
+	// Name identifies this command to others that depend on it, and in output when running in parallel
+	Name      string `json:"name"`
+	CmdString string `json:"cmd"`
+	// DependsOn lists the names of commands that must finish successfully before this one is started
+	DependsOn    []string       `json:"depends_on"`
+	Expectations []*Expectation `json:"expectations"`
+	// Retries is the number of times this command is run again after failing
+	Retries int `json:"retries"`
+	// RetryDelay is how long to wait before the first retry
+	RetryDelay Duration `json:"retry_delay"`
+	// Backoff multiplies the retry delay after every failed retry, giving exponential backoff when greater than 1
+	Backoff float64 `json:"backoff"`
+	// RetryOn limits retries to failures with certain exit codes or output, if nil every failure is retried
+	RetryOn *RetryOn `json:"retry_on"`
+	// IgnoreErrors lets the run, and this command's dependents, carry on even if this command fails
+	IgnoreErrors bool `json:"ignore_errors"`
+	// Creates skips this command if the given path already exists
+	Creates string `json:"creates"`
+	// Removes skips this command if the given path does not exist
+	Removes string `json:"removes"`
+	// OnlyIf skips this command unless the given check command exits successfully
+	OnlyIf string `json:"only_if"`
+	// Unless skips this command if the given check command exits successfully
+	Unless string `json:"unless"`
+	// OnFailure are run after this command has failed and has no retries left
+	OnFailure SilentCmds `json:"on_failure"`
+	// OnSuccess are run after this command has succeeded
+	OnSuccess SilentCmds `json:"on_success"`
+	// Always are run after this command has either succeeded or failed, but not if it was skipped
+	Always SilentCmds `json:"always"`
+	// GlobalExpectations are consulted after Expectations and are never used up.
+	// They are normally shared between every command loaded from the same Config.
+	GlobalExpectations []*Expectation `json:"-"`
+	ReceiveBuffer      *bytes.Buffer  `json:"-"`
+	ReadChan           chan string    `json:"-"`
+	ErrChan            chan error     `json:"-"`
+	ErrStringChan      chan string    `json:"-"`
+	coloredUI          ui.Ui
+	// declared holds the expectations as they were before any were matched, so they can be restored by Init
+	declared []*Expectation
+	// output is everything read from the command during the current attempt
+	output   *bytes.Buffer
+	exitCode int
+	done     chan struct{}
+	timeout  <-chan time.Time
+	// opts are the options for the current run, see options
+	opts *RunOptions
+	// attempts counts the executions in the current run
+	attempts int
+	// id is the CommandID of the events sent during the current run
+	id int
+	// masked maps the templated fields that used secret variables to their text with those values masked,
+	// see shown
+	masked map[string]string
 }
 
 // Expectation is a structure that stores expected input and output coming from and to another application
 type Expectation struct {
 	Input  string `json:"input"`
 	Output string `json:"output"`
+	// Regexp treats Input as a regular expression rather than plain text
+	Regexp bool `json:"regex"`
+	// Secret keeps Output out of logs and transcripts, for passwords and the like
+	Secret bool `json:"secret"`
+	re     *regexp.Regexp
 }
 
 // NewSilentCmd returns a new SilentCmd with all of its fields initialized (except expected cases)
@@ -46,80 +105,139 @@ func NewSilentCmd() *SilentCmd {
 		ErrChan:       make(chan error),
 		ErrStringChan: make(chan string),
 		coloredUI:     ui.NewColoredUi(),
+		output:        bytes.NewBuffer([]byte{}),
+		exitCode:      -1,
+		done:          make(chan struct{}),
 	}
 }
 
 // SilentCmds is a slice of *SilentCmd
 type SilentCmds []*SilentCmd
 
-// Exec executes all commands stored in s
-func (s SilentCmds) Exec() error {
-	for _, cmd := range s {
-		err := cmd.Exec()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+// Exec executes all commands stored in s one at a time with the given options, in dependency order, stopping at
+// the first error. io.EOF is returned if every command finished successfully
+func (s SilentCmds) Exec(opts ...RunOption) error {
+	r := &Runner{Commands: s, Parallel: 1, Policy: FailFast}
+	return r.Run(opts...)
 }
 
-// NewSilentCmdsFromJSON loads a list of commands and inputs/outputs from a JSON file
+// NewSilentCmdsFromJSON loads a list of commands and inputs/outputs from a JSON file.
+// Any global expectations declared in the config are attached to every command.
 func NewSilentCmdsFromJSON(configData []byte) (SilentCmds, error) {
-	cmds := SilentCmds{}
-	err := json.Unmarshal(configData, &cmds)
+	cfg, err := NewConfigFromJSON(configData)
 	if err != nil {
 		return nil, err
 	}
-	envMap := make(map[string]string)
-	for _, s := range os.Environ() {
-		kv := strings.Split(s, "=")
-		envMap[kv[0]] = kv[1]
-	}
-	for _, c := range cmds {
-		// because we've loaded from json we have to initialize the command's nil fields here
-		c.Init()
-		err = c.ExecTemplate(envMap)
-		if err != nil {
-			return nil, err
-		}
-		// naive but done for speed of dev
-		args := strings.Split(c.CmdString, " ")
-		if len(args) > 1 {
-			fmt.Println("printing args")
-			fmt.Println(args)
-			c.Cmd = exec.Command(args[0], args[1:]...)
-		} else {
-			c.Cmd = exec.Command(args[0])
-		}
-		c.Cmd.Env = os.Environ()
-
-	}
-	return cmds, nil
+	return cfg.Commands, nil
 }
 
-// Init initializes this command's nil fields
+// Init initializes this command's nil fields, and resets its buffers and channels so it can be executed again.
+// Expectations used up by a previous Run are restored, and if the command has already been started, s.Cmd
+// is replaced by a fresh copy of itself
 func (s *SilentCmd) Init() {
 	s.ReceiveBuffer = bytes.NewBuffer([]byte{})
 	s.ReadChan = make(chan string)
 	s.ErrChan = make(chan error)
 	s.ErrStringChan = make(chan string)
-	s.coloredUI = ui.NewColoredUi()
+	s.output = bytes.NewBuffer([]byte{})
+	s.exitCode = -1
+	s.done = make(chan struct{})
+	s.timeout = nil
+	if s.coloredUI == nil {
+		s.coloredUI = ui.NewColoredUi()
+	}
+	if s.declared != nil {
+		s.Expectations = append([]*Expectation(nil), s.declared...)
+	}
+	if s.Cmd != nil && s.Cmd.Process != nil {
+		cmd := exec.Command(s.Cmd.Path)
+		cmd.Args = s.Cmd.Args
+		cmd.Env = s.Cmd.Env
+		cmd.Dir = s.Cmd.Dir
+		cmd.SysProcAttr = s.Cmd.SysProcAttr
+		s.Cmd = cmd
+	}
+}
+
+// DisplayName returns the name of this command, or if it has no name its command string or connect target,
+// with any secret variables they use masked
+func (s *SilentCmd) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.CmdString == "" {
+		return s.shown(s.Connect)
+	}
+	return s.shown(s.CmdString)
+}
+
+// shown returns field, one of the command's templated fields, as it may be shown in output, logs and reports:
+// with the values of any secret variables it used masked
+func (s *SilentCmd) shown(field string) string {
+	if masked, ok := s.masked[field]; ok {
+		return masked
+	}
+	return field
 }
 
-// ExecTemplate parses a map replacing templated values in the command string
+// ExecTemplate parses a map replacing templated values in the command string, connect target, guards and
+// expectations' responses
 func (s *SilentCmd) ExecTemplate(m map[string]string) error {
-	t, err := template.New("envBuilder").Parse(s.CmdString)
-	if err == nil {
-		w := bytes.NewBuffer([]byte{})
-		err = t.Execute(w, m)
+	return s.execTemplate(m, nil)
+}
+
+// execTemplate is ExecTemplate, also keeping a copy of each field using any of the secret variables with their
+// values masked, for shown
+func (s *SilentCmd) execTemplate(m map[string]string, secrets map[string]bool) error {
+	var masked map[string]string
+	for _, field := range s.templated() {
+		text, err := executeTemplate(*field, m)
 		if err != nil {
 			return err
 		}
-		s.CmdString = w.String()
+		if usesVars(*field, secrets) {
+			if masked == nil {
+				masked = maskVars(m, secrets)
+			}
+			shown, err := executeTemplate(*field, masked)
+			if err != nil {
+				return err
+			}
+			if s.masked == nil {
+				s.masked = make(map[string]string)
+			}
+			s.masked[text] = shown
+		}
+		*field = text
 	}
 	return nil
 }
 
+// executeTemplate replaces templated values in text with values from m. Text that doesn't parse is left as it is
+func executeTemplate(text string, m map[string]string) (string, error) {
+	if text == "" {
+		return text, nil
+	}
+	t, err := template.New("envBuilder").Parse(text)
+	if err != nil {
+		return text, nil
+	}
+	w := bytes.NewBuffer([]byte{})
+	if err = t.Execute(w, m); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// templated returns the fields of the command that are templated
+func (s *SilentCmd) templated() []*string {
+	fields := []*string{&s.CmdString, &s.Connect, &s.Creates, &s.Removes, &s.OnlyIf, &s.Unless}
+	for _, e := range s.Expectations {
+		fields = append(fields, &e.Output)
+	}
+	return fields
+}
+
 // Pipes returns stdin, stdout, and stderr of this command
 func (s *SilentCmd) Pipes() (i io.WriteCloser, o io.ReadCloser, e io.ReadCloser, err error) {
 	if i, err = s.Cmd.StdinPipe(); err != nil {
@@ -134,48 +252,148 @@ func (s *SilentCmd) Pipes() (i io.WriteCloser, o io.ReadCloser, e io.ReadCloser,
 	return
 }
 
-// Exec executes this SilentCmd, blocking until EOF
-func (s *SilentCmd) Exec() error {
-	if s.Cmd == nil {
-		return errors.New("s.Cmd must not be nil")
+// ExitCode returns the exit code of the last execution of this command, or -1 if it has not exited
+func (s *SilentCmd) ExitCode() int {
+	return s.exitCode
+}
+
+// Output returns everything read from this command during its last execution
+func (s *SilentCmd) Output() string {
+	if s.output == nil {
+		return ""
 	}
+	return s.output.String()
+}
+
+// Exec executes this SilentCmd once with the given options, blocking until EOF and the command has exited.
+// io.EOF is returned if the command ran to completion and exited successfully
+func (s *SilentCmd) Exec(opts ...RunOption) error {
+	s.opts = NewRunOptions(opts...)
+	s.id = s.opts.nextID()
+	return s.exec()
+}
 
-	i, o, e, err := s.Pipes()
+// exec is Exec using the options already set for this run
+func (s *SilentCmd) exec() error {
+	if s.Timeout.Duration > 0 {
+		s.timeout = s.options().Clock.After(s.Timeout.Duration)
+	}
+	l := s.logger()
+	t, err := s.transport()
 	if err != nil {
 		return err
 	}
+	if err = t.Start(); err != nil {
+		l.Debug("could not start", Fields{"error": err})
+		return err
+	}
+	l.Debug("started", Fields{"transport": fmt.Sprintf("%T", t)})
 
-	closeFunc := func() {
-		i.Close()
-		o.Close()
-		e.Close()
+	// channels are passed explicitly so that readers outliving this execution never see those made by a later Init.
+	// stderr closing doesn't mean the command is finished, so only stdout reports its errors (including io.EOF)
+	stdoutClosed, stderrClosed := make(chan struct{}), make(chan struct{})
+	go func() {
+		readToChannel(t.Stdout(), s.ReadChan, s.ErrChan, s.done)
+		close(stdoutClosed)
+	}()
+	if e := t.Stderr(); e == nil {
+		close(stderrClosed)
+	} else {
+		go func() {
+			readToChannel(e, s.ErrStringChan, nil, s.done)
+			close(stderrClosed)
+		}()
+	}
+
+	err = s.Receive(t.Stdin())
+	if err != io.EOF {
+		// we gave up on the command, make sure it doesn't hang around waiting for input
+		l.Debug("killing", Fields{"error": err})
+		t.Signal(os.Kill)
+	}
+	// stdout and stderr are read separately, so whichever Receive didn't finish with may still have output on its way
+	if rest := s.drain(stdoutClosed, stderrClosed, drainTimeout); rest != "" && err == io.EOF {
+		err = errors.New(rest)
+	}
+	close(s.done)
+	t.Stdin().Close()
+	waitErr := t.Wait()
+	s.exitCode = exitStatus(waitErr)
+	l.Debug("exited", Fields{"exit_code": s.exitCode, "output_bytes": s.output.Len()})
+	if err == io.EOF && waitErr != nil {
+		return s.failure(waitErr)
+	}
+	if err != io.EOF {
+		return s.failure(err)
 	}
+	return err
+}
 
-	defer closeFunc()
+// options returns the options for the current run, or the defaults if the command isn't being run,
+// e.g. when Receive is called directly
+func (s *SilentCmd) options() *RunOptions {
+	if s.opts == nil {
+		return NewRunOptions()
+	}
+	return s.opts
+}
 
-	err = s.Cmd.Start()
-	if err != nil {
-		return err
+// ui returns the ui this command's output is shown on during the current run
+func (s *SilentCmd) ui() ui.Ui {
+	if o := s.options(); o.Ui != nil {
+		return o.Ui
 	}
+	return s.coloredUI
+}
 
-	go func() {
-		s.Read(o)
-	}()
+// logger returns the logger for the current run, adding this command's name to every entry
+func (s *SilentCmd) logger() *Logger {
+	return s.options().Logger.With(Fields{"command": s.DisplayName()})
+}
 
-	go func() {
-		s.ReadErr(e)
-	}()
+// transport returns the Transport to execute this command with. That's s.Transport if it is set, then a connection
+// to s.Connect, and otherwise s.Cmd is run either with pipes or a pty
+func (s *SilentCmd) transport() (Transport, error) {
+	if s.Transport != nil {
+		return s.Transport, nil
+	}
+	if s.Connect != "" {
+		return NewConnectTransport(s.Connect, s.Timeout.Duration)
+	}
+	if s.Cmd == nil {
+		return nil, errors.New("s.Cmd must not be nil")
+	}
+	if s.Pty {
+		return &PtyTransport{Cmd: s.Cmd}, nil
+	}
+	return &ExecTransport{Cmd: s.Cmd}, nil
+}
 
-	return s.Receive(i)
+// exitStatus returns the exit code of a process from the error returned by Wait
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	return -1
 }
 
 // Write writes l (line) to the provided writer, returning an error if any
 func (s *SilentCmd) Write(l string, writer io.Writer) error {
+	_, err := writer.Write([]byte(line(l)))
+	return err
+}
+
+// line returns l ending with a newline, adding one if it doesn't already
+func line(l string) string {
 	if !strings.HasSuffix(l, "\n") {
-		l = l + "\n"
+		return l + "\n"
 	}
-	_, err := writer.Write([]byte(l))
-	return err
+	return l
 }
 
 // Read reads data from reader into s.ReadChan
@@ -188,60 +406,155 @@ func (s *SilentCmd) ReadErr(reader io.Reader) {
 	s.ReadToChannel(reader, s.ErrStringChan)
 }
 
-// ReadToChannel reads from reader to the channel ch
+// ReadToChannel reads from reader to the channel ch until reader returns an error, which is sent to s.ErrChan.
+// It gives up early if the command finishes executing before everything has been received
 func (s *SilentCmd) ReadToChannel(reader io.Reader, ch chan string) {
+	readToChannel(reader, ch, s.ErrChan, s.done)
+}
+
+// readToChannel is ReadToChannel with its channels given explicitly, if errCh is nil errors are not reported
+func readToChannel(reader io.Reader, ch chan string, errCh chan error, done chan struct{}) {
 	// whoa here's a buffer
 	data := make([]byte, 256)
 	for {
 		bytesRead, err := reader.Read(data)
-		if err != nil {
-			s.ErrChan <- err
-		}
-		ch <- string(data[:bytesRead])
-		// clear the buffer if necessary - i'd love to see a better/more efficient way to do this
 		if bytesRead > 0 {
-			data = append(data[bytesRead:], make([]byte, bytesRead)...)
+			select {
+			case ch <- string(data[:bytesRead]):
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			if errCh != nil {
+				select {
+				case errCh <- err:
+				case <-done:
+				}
+			}
+			return
 		}
 	}
 }
 
 // Receive loops on s.ReadChan, s.ErrChan, and s.ErrStringChan, selecting the first that occurs each iteration.
 // If readchan receives then we are collecting input from stdout, if there is an error sent to s.ErrChan or s.ErrStringChan,
-// we return the error. io.EOF is the expected case when no error actually occurred
+// we return the error. io.EOF is the expected case when no error actually occurred.
+// If the command has a Timeout and it runs out while executing, an error is returned
 func (s *SilentCmd) Receive(w io.Writer) error {
+	o := s.options()
+	l := s.logger()
 	for {
 		select {
 		case str := <-s.ReadChan:
-			if Verbose {
-				// gives more specific info for debugging
-				log.Println(str)
-			}
-			s.coloredUI.Say(str)
-			s.ReceiveBuffer.WriteString(str)
-
+			s.receiveStdout(str)
 			match, expected := s.Match(s.ReceiveBuffer.String())
 			if match {
+				l.Debug("matched expectation", Fields{"expectation": expected.Input, "response": expected.MaskedOutput()})
+				o.emit(&Event{Type: EventExpectationMatched, Command: s.DisplayName(), CommandID: s.id,
+					Attempt: s.attempts, Expectation: expected.Input, Awaiting: s.awaiting()})
+				o.transcribe(line(expected.MaskedOutput()))
+				o.emit(&Event{Type: EventResponseSent, Command: s.DisplayName(), CommandID: s.id,
+					Attempt: s.attempts, Expectation: expected.Input, Text: line(expected.MaskedOutput())})
 				s.Write(expected.Output, w)
 				s.ReceiveBuffer.Reset()
 			}
 		case err := <-s.ErrChan:
 			return err
 		case errStr := <-s.ErrStringChan:
+			s.receiveStderr(errStr)
 			return errors.New(errStr)
+		case <-s.timeout:
+			return fmt.Errorf("%s timed out after %s", s.DisplayName(), s.Timeout)
 		}
 	}
 }
 
-// Match checks the buffer string against expected cases, removing from the list when one is found
+// drainTimeout is how long the rest of a command's output is waited for once Receive has returned, in case something
+// it started still has stdout or stderr open
+const drainTimeout = 250 * time.Millisecond
+
+// receiveStdout shows str, read from the command's stdout, and records it in its receive buffer, output, logs and
+// events
+func (s *SilentCmd) receiveStdout(str string) {
+	o := s.options()
+	// gives more specific info for debugging
+	s.logger().Trace("read", Fields{"stream": "stdout", "bytes": len(str), "text": str})
+	o.transcribe(str)
+	o.emit(&Event{Type: EventOutputChunk, Command: s.DisplayName(), CommandID: s.id, Attempt: s.attempts,
+		Stream: "stdout", Text: str})
+	s.ui().Say(str)
+	s.ReceiveBuffer.WriteString(str)
+	if s.output != nil {
+		s.output.WriteString(str)
+	}
+}
+
+// receiveStderr records str, read from the command's stderr, in its output, logs and events
+func (s *SilentCmd) receiveStderr(str string) {
+	o := s.options()
+	s.logger().Trace("read", Fields{"stream": "stderr", "bytes": len(str), "text": str})
+	o.transcribe(str)
+	o.emit(&Event{Type: EventOutputChunk, Command: s.DisplayName(), CommandID: s.id, Attempt: s.attempts,
+		Stream: "stderr", Text: str})
+	if s.output != nil {
+		s.output.WriteString(str)
+	}
+}
+
+// drain receives what's left of the command's output after Receive has returned, until the readers of stdout and
+// stderr say they have reached the end by closing their channels, or wait runs out. Nothing is matched any more, the
+// output is only recorded. What was left on stderr is returned
+func (s *SilentCmd) drain(stdoutClosed, stderrClosed <-chan struct{}, wait time.Duration) string {
+	var rest bytes.Buffer
+	// real time rather than the run's clock, as this is about the pipes rather than the command
+	timeout := time.After(wait)
+	for stdoutClosed != nil || stderrClosed != nil {
+		select {
+		case str := <-s.ReadChan:
+			s.receiveStdout(str)
+		case <-s.ErrChan:
+			// stdout's reader is finishing, and closes stdoutClosed next
+		case str := <-s.ErrStringChan:
+			s.receiveStderr(str)
+			rest.WriteString(str)
+		case <-stdoutClosed:
+			stdoutClosed = nil
+		case <-stderrClosed:
+			stderrClosed = nil
+		case <-timeout:
+			return rest.String()
+		}
+	}
+	return rest.String()
+}
+
+// awaiting returns the input of the first of the command's own expectations that hasn't been matched yet,
+// or an empty string if they all have
+func (s *SilentCmd) awaiting() string {
+	if len(s.Expectations) == 0 {
+		return ""
+	}
+	return s.Expectations[0].Input
+}
+
+// Match checks the buffer string against expected cases, removing from the list when one is found.
+// If none of the command's own expectations match, the global expectations are checked; those are
+// left in place so they can match any number of times.
 func (s *SilentCmd) Match(bufferString string) (match bool, expectation *Expectation) {
 	for i, e := range s.Expectations {
 		// naive check - thinking about fuzzy matching here but open to ideas.
 		// Maybe just check for the exact length of what's expected?
 		// Don't want to get caught on possible extra white space though.
-		if strings.Contains(bufferString, e.Input) {
+		if e.Find(bufferString) != nil {
 			s.Expectations = append(s.Expectations[:i], s.Expectations[i+1:]...)
 			return true, e
 		}
 	}
+	for _, e := range s.GlobalExpectations {
+		if e.Find(bufferString) != nil {
+			return true, e
+		}
+	}
 	return
 }
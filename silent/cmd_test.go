@@ -23,10 +23,6 @@ const (
 	testDataPath = repoPath + "/silent/test_data"
 )
 
-func init() {
-	Verbose = true
-}
-
 func TestSilentCmd_Read(t *testing.T) {
 	Convey("We can test the silent command's read function", t, func() {
 		s := NewSilentCmd()
@@ -139,6 +135,10 @@ func loadNoNewlineTestConfig() ([]byte, error) {
 	return loadConfig("/no_newline_example_config.json")
 }
 
+func loadGlobalExpectationsConfig() ([]byte, error) {
+	return loadConfig("/global_expectations_example_config.json")
+}
+
 func loadConfig(path string) ([]byte, error) {
 	gopath := os.Getenv("GOPATH")
 	return ioutil.ReadFile(gopath + testDataPath + path)
@@ -0,0 +1,127 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Config is the top level structure of a silentinstall config file.
+//
+// A config may either be a plain list of SilentCmds (the original format), or an object:
+//
+//	{
+//	  "vars": {"NAME": {"description": "who to install for", "prompt": true}},
+//	  "global_expectations": [{"input": "Press RETURN to continue", "output": ""}],
+//	  "commands": [{"cmd": "..."}]
+//	}
+type Config struct {
+	// Vars declares the variables commands use, see Var
+	Vars map[string]*Var `json:"vars"`
+	// GlobalExpectations are checked for every command after that command's own expectations
+	// and may match any number of times
+	GlobalExpectations []*Expectation `json:"global_expectations"`
+	Commands           SilentCmds     `json:"commands"`
+	// OnFailure, OnSuccess and Always are hooks run once all of the commands have finished
+	OnFailure SilentCmds `json:"on_failure"`
+	OnSuccess SilentCmds `json:"on_success"`
+	Always    SilentCmds `json:"always"`
+}
+
+// NewConfigFromJSON loads a Config from JSON, templating and initializing every command it contains.
+// Variables are taken from the environment unless opts say otherwise, and a *MissingVarsError is returned
+// listing every variable without a value
+func NewConfigFromJSON(configData []byte, opts ...LoadOption) (*Config, error) {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg := &Config{}
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(configData), []byte("[")) {
+		err = json.Unmarshal(configData, &cfg.Commands)
+	} else {
+		err = json.Unmarshal(configData, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := cfg.resolveVars(o)
+	if err != nil {
+		return nil, err
+	}
+	cfg.markSecrets(cfg.GlobalExpectations)
+	for _, e := range cfg.GlobalExpectations {
+		if err = e.Compile(); err != nil {
+			return nil, err
+		}
+		if e.Output, err = executeTemplate(e.Output, vars); err != nil {
+			return nil, err
+		}
+	}
+	for _, cmds := range []SilentCmds{cfg.Commands, cfg.OnFailure, cfg.OnSuccess, cfg.Always} {
+		if err = cfg.prepare(cmds, vars); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Runner returns a Runner for the config's commands and hooks
+func (cfg *Config) Runner() *Runner {
+	return &Runner{
+		Commands:  cfg.Commands,
+		OnFailure: cfg.OnFailure,
+		OnSuccess: cfg.OnSuccess,
+		Always:    cfg.Always,
+	}
+}
+
+// prepare initializes and templates cmds and their hooks after they have been loaded
+func (cfg *Config) prepare(cmds SilentCmds, vars map[string]string) error {
+	for _, c := range cmds {
+		// because we've loaded from json we have to initialize the command's nil fields here
+		c.Init()
+		c.GlobalExpectations = cfg.GlobalExpectations
+		if c.RetryOn != nil {
+			if err := c.RetryOn.Compile(); err != nil {
+				return err
+			}
+		}
+		cfg.markSecrets(c.Expectations)
+		for _, e := range c.Expectations {
+			if err := e.Compile(); err != nil {
+				return err
+			}
+		}
+		if err := c.execTemplate(vars, cfg.secretVars()); err != nil {
+			return err
+		}
+		if c.Connect != "" {
+			// make sure the target is valid up front
+			if _, err := NewConnectTransport(c.Connect, c.Timeout.Duration); err != nil {
+				return err
+			}
+		} else {
+			c.Cmd = commandFromString(c.CmdString)
+		}
+		for _, hooks := range []SilentCmds{c.OnFailure, c.OnSuccess, c.Always} {
+			if err := cfg.prepare(hooks, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commandFromString builds an *exec.Cmd running in the current environment from a command string
+func commandFromString(cmdString string) *exec.Cmd {
+	// naive but done for speed of dev
+	args := strings.Split(cmdString, " ")
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	return cmd
+}
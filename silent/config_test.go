@@ -0,0 +1,58 @@
+package silent
+
+import (
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewConfigFromJSON(t *testing.T) {
+	Convey("We can load the original list format as a config", t, func() {
+		data, err := loadBasicTestConfig()
+		So(err, ShouldBeNil)
+		cfg, err := NewConfigFromJSON(data)
+		So(err, ShouldBeNil)
+		So(len(cfg.Commands), ShouldEqual, 1)
+		So(cfg.GlobalExpectations, ShouldBeEmpty)
+	})
+
+	Convey("We can load a config with global expectations", t, func() {
+		data, err := loadGlobalExpectationsConfig()
+		So(err, ShouldBeNil)
+		cfg, err := NewConfigFromJSON(data)
+		So(err, ShouldBeNil)
+		So(len(cfg.GlobalExpectations), ShouldEqual, 1)
+		So(len(cfg.Commands), ShouldEqual, 1)
+		So(cfg.Commands[0].GlobalExpectations, ShouldResemble, cfg.GlobalExpectations)
+
+		Convey("And global expectations answer every matching prompt", func() {
+			err = cfg.Commands.Exec()
+			So(err, ShouldEqual, io.EOF)
+		})
+	})
+}
+
+func TestSilentCmd_MatchGlobal(t *testing.T) {
+	Convey("Global expectations are checked after a command's own and are never used up", t, func() {
+		global := &Expectation{Input: "--More--", Output: " "}
+		s := NewSilentCmd()
+		s.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+		s.GlobalExpectations = []*Expectation{global}
+
+		match, e := s.Match("--More-- Name?")
+		So(match, ShouldBeTrue)
+		So(e.Output, ShouldEqual, "Chris")
+		So(s.Expectations, ShouldBeEmpty)
+
+		for i := 0; i < 3; i++ {
+			match, e = s.Match("--More--")
+			So(match, ShouldBeTrue)
+			So(e, ShouldEqual, global)
+		}
+		So(len(s.GlobalExpectations), ShouldEqual, 1)
+
+		match, _ = s.Match("nothing to see here")
+		So(match, ShouldBeFalse)
+	})
+}
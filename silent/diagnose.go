@@ -0,0 +1,141 @@
+package silent
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	// diagnosticLines is the number of lines of output shown in a FailureError
+	diagnosticLines = 10
+	// diagnosticBuffer is the most of the receive buffer shown in a FailureError, and searched for near misses
+	diagnosticBuffer = 2048
+)
+
+// FailureError is the error of a failed execution, with what the command had printed and what it was still waiting
+// for, so that a prompt that changed between versions of an installer is easy to spot
+type FailureError struct {
+	// Err is why the execution failed
+	Err error
+	// Tail is the last lines of the command's output, stdout and stderr
+	Tail string
+	// Buffer is the end of the output received since the last expectation was matched
+	Buffer string
+	// Outstanding are the command's own expectations that were never matched
+	Outstanding []*NearMiss
+}
+
+// NearMiss is an expectation that was never matched, with the text of the receive buffer closest to it
+type NearMiss struct {
+	Input  string
+	Regexp bool
+	// Closest is the text in the buffer with the fewest edits from Input, empty if nothing was close
+	Closest string
+	// Distance is the number of edits between Closest and Input
+	Distance int
+}
+
+// failure returns err with diagnostics of the command's current execution, or err itself if there is nothing to add
+func (s *SilentCmd) failure(err error) error {
+	f := &FailureError{Err: err, Tail: tail(s.Output(), diagnosticLines)}
+	buffer := s.ReceiveBuffer.String()
+	if len(buffer) > diagnosticBuffer {
+		buffer = buffer[len(buffer)-diagnosticBuffer:]
+	}
+	f.Buffer = buffer
+	for _, e := range s.Expectations {
+		miss := &NearMiss{Input: e.Input, Regexp: e.Regexp}
+		if !e.Regexp {
+			// there's no distance to a pattern, only to literal text. Text needing more than half of the
+			// expectation changed isn't close enough to be what was meant
+			if closest, distance := nearMiss(e.Input, buffer); distance*2 <= len([]rune(e.Input)) {
+				miss.Closest, miss.Distance = closest, distance
+			}
+		}
+		f.Outstanding = append(f.Outstanding, miss)
+	}
+	if f.Tail == "" && f.Buffer == "" && len(f.Outstanding) == 0 {
+		return err
+	}
+	return f
+}
+
+// Error returns the cause of the failure followed by the diagnostics
+func (e *FailureError) Error() string {
+	var b bytes.Buffer
+	b.WriteString(strings.TrimSuffix(e.Err.Error(), "\n"))
+	if e.Tail != "" {
+		b.WriteString("\nlast output:")
+		for _, l := range strings.Split(strings.TrimSuffix(e.Tail, "\n"), "\n") {
+			b.WriteString("\n    " + l)
+		}
+	}
+	if e.Buffer != "" {
+		fmt.Fprintf(&b, "\nreceived but not matched: %q", e.Buffer)
+	}
+	if len(e.Outstanding) > 0 {
+		b.WriteString("\nstill waiting for:")
+		for _, m := range e.Outstanding {
+			fmt.Fprintf(&b, "\n    %q", m.Input)
+			switch {
+			case m.Regexp:
+				b.WriteString(" (pattern)")
+			case m.Closest != "":
+				fmt.Fprintf(&b, ", closest was %q (%d edit(s) away)", m.Closest, m.Distance)
+			default:
+				b.WriteString(", nothing close was received")
+			}
+		}
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause of the failure, so errors.As and errors.Is see through the diagnostics
+func (e *FailureError) Unwrap() error {
+	return e.Err
+}
+
+// failureCause returns the error a FailureError wraps, or err if it isn't one
+func failureCause(err error) error {
+	if f, ok := err.(*FailureError); ok {
+		return f.Err
+	}
+	return err
+}
+
+// nearMiss returns the text in s with the fewest edits (insertions, deletions or substitutions) from pattern,
+// and how many edits that is. Earlier text wins ties
+func nearMiss(pattern, s string) (string, int) {
+	p, text := []rune(pattern), []rune(s)
+	// dist[i] is the edit distance between p[:i] and the best text ending at the current position, and start[i]
+	// is where that text starts. Text can start anywhere, so an empty pattern is always 0 edits away
+	dist, start := make([]int, len(p)+1), make([]int, len(p)+1)
+	for i := range dist {
+		dist[i] = i
+	}
+	best, bestStart, bestEnd := dist[len(p)], 0, 0
+	prev, prevStart := make([]int, len(p)+1), make([]int, len(p)+1)
+	for j := 1; j <= len(text); j++ {
+		copy(prev, dist)
+		copy(prevStart, start)
+		dist[0], start[0] = 0, j
+		for i := 1; i <= len(p); i++ {
+			cost := 1
+			if p[i-1] == text[j-1] {
+				cost = 0
+			}
+			dist[i], start[i] = prev[i-1]+cost, prevStart[i-1]
+			if d := dist[i-1] + 1; d < dist[i] {
+				dist[i], start[i] = d, start[i-1]
+			}
+			if d := prev[i] + 1; d < dist[i] {
+				dist[i], start[i] = d, prevStart[i]
+			}
+		}
+		if dist[len(p)] < best {
+			best, bestStart, bestEnd = dist[len(p)], start[len(p)], j
+		}
+	}
+	return string(text[bestStart:bestEnd]), best
+}
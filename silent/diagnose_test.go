@@ -0,0 +1,127 @@
+package silent
+
+import (
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNearMiss(t *testing.T) {
+	Convey("The closest text to a pattern is found anywhere in the output", t, func() {
+		closest, distance := nearMiss("Password:", "Welcome to the installer\nPasswd: ")
+		So(closest, ShouldEqual, "Passwd:")
+		So(distance, ShouldEqual, 2)
+
+		closest, distance = nearMiss("Name?", "Your name? ")
+		So(closest, ShouldEqual, "name?")
+		So(distance, ShouldEqual, 1)
+
+		closest, distance = nearMiss("Continue?", "please Continue? [y/n]")
+		So(closest, ShouldEqual, "Continue?")
+		So(distance, ShouldEqual, 0)
+	})
+
+	Convey("Nothing is close in empty output", t, func() {
+		closest, distance := nearMiss("Name?", "")
+		So(closest, ShouldBeEmpty)
+		So(distance, ShouldEqual, 5)
+	})
+
+	Convey("Earlier text wins ties", t, func() {
+		closest, distance := nearMiss("abc", "abx aby")
+		So(closest, ShouldEqual, "ab")
+		So(distance, ShouldEqual, 1)
+	})
+}
+
+func TestFailureError(t *testing.T) {
+	Convey("A command failing before its expectations are matched says what it was waiting for", t, func() {
+		s := shellCmd("install", "printf 'Installing\\nName? '; read n; printf 'Passwd: '; exit 3")
+		s.Expectations = []*Expectation{
+			{Input: "Name?", Output: "bob"},
+			{Input: "Password:", Output: "x"},
+			{Input: "License key:", Output: "y"},
+			{Input: "[0-9]+ MB", Output: "z", Regexp: true},
+		}
+		result := s.Run()
+		So(result.Status, ShouldEqual, StatusFailed)
+		f, ok := result.Err.(*FailureError)
+		So(ok, ShouldBeTrue)
+		So(f.Err.Error(), ShouldEqual, "exit status 3")
+		So(f.Tail, ShouldEqual, "Installing\nName? Passwd: ")
+		So(f.Buffer, ShouldEqual, "Passwd: ")
+		So(len(f.Outstanding), ShouldEqual, 3)
+		So(f.Outstanding[0], ShouldResemble, &NearMiss{Input: "Password:", Closest: "Passwd:", Distance: 2})
+		So(f.Outstanding[1].Closest, ShouldBeEmpty)
+		So(f.Outstanding[2].Regexp, ShouldBeTrue)
+
+		So(f.Error(), ShouldEqual, `exit status 3
+last output:
+    Installing
+    Name? Passwd: `+`
+received but not matched: "Passwd: "
+still waiting for:
+    "Password:", closest was "Passwd:" (2 edit(s) away)
+    "License key:", nothing close was received
+    "[0-9]+ MB" (pattern)`)
+
+		Convey("The exit error can still be found", func() {
+			var exitErr *exec.ExitError
+			So(errors.As(result.Err, &exitErr), ShouldBeTrue)
+			So(exitErr.ExitCode(), ShouldEqual, 3)
+		})
+
+		Convey("The first line is still just the cause", func() {
+			So(firstLine(result.Err.Error(), 200), ShouldEqual, "exit status 3")
+			data, err := json.Marshal(result)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, `"error":"exit status 3"`)
+		})
+	})
+
+	Convey("Failures with nothing to add are left alone", t, func() {
+		err := shellCmd("broken", "exit 3").Exec()
+		So(err, ShouldNotBeNil)
+		_, ok := err.(*FailureError)
+		So(ok, ShouldBeFalse)
+		So(err.Error(), ShouldEqual, "exit status 3")
+	})
+
+	Convey("Stderr written just before exiting is never lost", t, func() {
+		s := shellCmd("install", "echo installed; echo 'license expired' >&2")
+		f, ok := s.Run().Err.(*FailureError)
+		So(ok, ShouldBeTrue)
+		So(f.Err.Error(), ShouldEqual, "license expired\n")
+		So(f.Tail, ShouldContainSubstring, "installed\n")
+		So(f.Tail, ShouldContainSubstring, "license expired\n")
+	})
+
+	Convey("Only the end of the output is shown", t, func() {
+		s := shellCmd("chatty", "seq 1 50; exit 1")
+		f := s.Run().Err.(*FailureError)
+		So(f.Tail, ShouldStartWith, "41\n")
+		So(strings.Count(f.Tail, "\n"), ShouldEqual, diagnosticLines)
+	})
+
+	Convey("Retries are announced with just the cause", t, func() {
+		sink := &recordingSink{}
+		s := shellCmd("flaky", "echo nope; exit 2")
+		s.Retries = 1
+		So(s.Run(WithEventSink(sink)).Err, ShouldHaveSameTypeAs, &FailureError{})
+		for _, e := range sink.events {
+			if e.Type == EventRetry {
+				So(e.Error, ShouldEqual, "exit status 2")
+			}
+		}
+	})
+
+	Convey("The cause of other errors is themselves", t, func() {
+		err := errors.New("boom")
+		So(failureCause(err), ShouldEqual, err)
+		So(failureCause(&FailureError{Err: err, Tail: "x"}), ShouldEqual, err)
+	})
+}
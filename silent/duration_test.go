@@ -0,0 +1,29 @@
+package silent
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	Convey("Durations can be read from JSON strings or numbers of seconds", t, func() {
+		var d Duration
+		So(json.Unmarshal([]byte(`"1m30s"`), &d), ShouldBeNil)
+		So(d.Duration, ShouldEqual, 90*time.Second)
+
+		So(json.Unmarshal([]byte(`2.5`), &d), ShouldBeNil)
+		So(d.Duration, ShouldEqual, 2500*time.Millisecond)
+
+		So(json.Unmarshal([]byte(`"soon"`), &d), ShouldNotBeNil)
+		So(json.Unmarshal([]byte(`true`), &d), ShouldNotBeNil)
+
+		Convey("And written back out as strings", func() {
+			data, err := json.Marshal(Duration{90 * time.Second})
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, `"1m30s"`)
+		})
+	})
+}
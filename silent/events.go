@@ -0,0 +1,118 @@
+package silent
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType says what an Event is about
+type EventType string
+
+const (
+	// EventRunStart is sent when a Runner starts, with the number of commands it has
+	EventRunStart EventType = "run_start"
+	// EventCommandStart is sent when a command starts running, before its guards are checked
+	EventCommandStart EventType = "command_start"
+	// EventAttemptStart is sent each time a command is executed, including retries, with the first expectation
+	// it is waiting for
+	EventAttemptStart EventType = "attempt_start"
+	// EventRetry is sent when an attempt has failed and the command will be retried, with the attempt's error
+	EventRetry EventType = "retry"
+	// EventOutputChunk is sent for every chunk of output read from a command
+	EventOutputChunk EventType = "output_chunk"
+	// EventExpectationMatched is sent when one of a command's expectations is found in its output
+	EventExpectationMatched EventType = "expectation_matched"
+	// EventResponseSent is sent when a response is written to a command, with secrets masked
+	EventResponseSent EventType = "response_sent"
+	// EventCommandEnd is sent with a command's Result once it has finished, or has been skipped
+	EventCommandEnd EventType = "command_end"
+	// EventRunEnd is sent when a Runner finishes, with its status and error if it failed
+	EventRunEnd EventType = "run_end"
+)
+
+// Event is something that happened during a run, for tools following its progress
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Command is the name of the command the event is about, if any
+	Command string `json:"command,omitempty"`
+	// CommandID tells the commands of a run apart, as their names needn't be unique: unnamed commands running
+	// the same thing, or a hook running the same thing as the command it's hooked on to
+	CommandID int `json:"command_id,omitempty"`
+	// Attempt is the command's current attempt, counting from 1
+	Attempt int `json:"attempt,omitempty"`
+	// Stream is "stdout" or "stderr" for output chunks
+	Stream string `json:"stream,omitempty"`
+	// Text is the output read, or the response sent
+	Text string `json:"text,omitempty"`
+	// Expectation is the input of the expectation matched, or responded to
+	Expectation string `json:"expectation,omitempty"`
+	// Awaiting is the input of the next of the command's own expectations it is waiting for, if any
+	Awaiting string `json:"awaiting,omitempty"`
+	// Commands is the number of commands in a run
+	Commands int     `json:"commands,omitempty"`
+	Result   *Result `json:"result,omitempty"`
+	// Status and Error describe how a run ended. Error is also why an attempt is being retried
+	Status Status `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventSink receives a run's events as they happen. Events may be sent from multiple goroutines at once
+type EventSink interface {
+	Event(e *Event)
+}
+
+// JSONLSink is an EventSink writing each event as a JSON object on its own line, known as JSON Lines
+type JSONLSink struct {
+	w io.Writer
+	l sync.Mutex
+}
+
+// NewJSONLSink returns a JSONLSink writing to w
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: w}
+}
+
+// Event implements EventSink. Events are best effort, so errors writing them are ignored
+func (s *JSONLSink) Event(e *Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.w.Write(append(data, '\n'))
+}
+
+// EventRecorder is an EventSink keeping every event it's sent, for reports built once a run has finished
+type EventRecorder struct {
+	events []*Event
+	l      sync.Mutex
+}
+
+// Event implements EventSink
+func (r *EventRecorder) Event(e *Event) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Events returns the events recorded so far, in the order they were sent
+func (r *EventRecorder) Events() []*Event {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return append([]*Event(nil), r.events...)
+}
+
+// emit timestamps e and sends it to every sink
+func (o *RunOptions) emit(e *Event) {
+	if len(o.Sinks) == 0 {
+		return
+	}
+	e.Time = o.Clock.Now().UTC()
+	for _, sink := range o.Sinks {
+		sink.Event(e)
+	}
+}
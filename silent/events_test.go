@@ -0,0 +1,161 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordingSink is an EventSink remembering every event it's sent
+type recordingSink struct {
+	l      sync.Mutex
+	events []*Event
+}
+
+func (s *recordingSink) Event(e *Event) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.events = append(s.events, e)
+}
+
+// types returns the types of the events sent about command, or every event if command is empty
+func (s *recordingSink) types(command string) []EventType {
+	var types []EventType
+	for _, e := range s.events {
+		if command == "" || e.Command == command {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+func TestEvents(t *testing.T) {
+	Convey("A run sends events as it progresses", t, func() {
+		sink := &recordingSink{}
+		s := shellCmd("login", "printf 'Password: '; read p; echo welcome")
+		s.Expectations = []*Expectation{{Input: "Password:", Output: "hunter2", Secret: true}}
+		r := &Runner{Commands: SilentCmds{s, shellCmd("after", "true", "login")}}
+		So(r.Run(WithEventSink(sink)), ShouldEqual, io.EOF)
+
+		types := sink.types("")
+		So(types[0], ShouldEqual, EventRunStart)
+		So(types[len(types)-1], ShouldEqual, EventRunEnd)
+		So(sink.events[0].Commands, ShouldEqual, 2)
+		So(sink.events[len(sink.events)-1].Status, ShouldEqual, StatusOK)
+
+		login := sink.types("login")
+		So(login[0], ShouldEqual, EventCommandStart)
+		So(login, ShouldContain, EventOutputChunk)
+		So(login[len(login)-1], ShouldEqual, EventCommandEnd)
+		So(sink.types("after"), ShouldResemble, []EventType{EventCommandStart, EventAttemptStart, EventCommandEnd})
+
+		for _, e := range sink.events {
+			So(e.Time.IsZero(), ShouldBeFalse)
+			switch e.Type {
+			case EventExpectationMatched:
+				So(e.Expectation, ShouldEqual, "Password:")
+				So(e.Attempt, ShouldEqual, 1)
+			case EventResponseSent:
+				So(e.Text, ShouldEqual, secretMask+"\n")
+			case EventCommandEnd:
+				So(e.Result.Status, ShouldEqual, StatusOK)
+			}
+		}
+		So(login, ShouldContain, EventExpectationMatched)
+		So(login, ShouldContain, EventResponseSent)
+	})
+
+	Convey("Events say what a command is waiting for", t, func() {
+		sink := &recordingSink{}
+		s := shellCmd("login", "printf 'User: '; read u; printf 'Password: '; read p")
+		s.Expectations = []*Expectation{{Input: "User:", Output: "admin"}, {Input: "Password:", Output: "hunter2"}}
+		So(s.Run(WithEventSink(sink)).Err, ShouldBeNil)
+		var awaiting []string
+		for _, e := range sink.events {
+			if e.Type == EventAttemptStart || e.Type == EventExpectationMatched {
+				awaiting = append(awaiting, e.Awaiting)
+			}
+		}
+		So(awaiting, ShouldResemble, []string{"User:", "Password:", ""})
+	})
+
+	Convey("Retries are announced between attempts", t, func() {
+		sink := &recordingSink{}
+		s := shellCmd("flaky", "echo nope; exit 2")
+		s.Retries = 1
+		So(s.Run(WithEventSink(sink)).Err, ShouldNotBeNil)
+		So(sink.types("flaky"), ShouldResemble, []EventType{EventCommandStart, EventAttemptStart, EventOutputChunk,
+			EventRetry, EventAttemptStart, EventOutputChunk, EventCommandEnd})
+		So(sink.events[3].Attempt, ShouldEqual, 1)
+		So(sink.events[3].Error, ShouldEqual, "exit status 2")
+	})
+
+	Convey("Failed runs and skipped commands are reported", t, func() {
+		sink := &recordingSink{}
+		r := &Runner{Commands: SilentCmds{shellCmd("broken", "echo oops >&2; exit 1"), shellCmd("skipped", "true")},
+			Skip: []string{"skipped"}, Policy: FinishIndependent}
+		So(r.Run(WithEventSink(sink)), ShouldNotEqual, io.EOF)
+		last := sink.events[len(sink.events)-1]
+		So(last.Type, ShouldEqual, EventRunEnd)
+		So(last.Status, ShouldEqual, StatusFailed)
+		// stderr is drained before the command is finished with, so it's the error whichever stream ends first
+		So(last.Error, ShouldEqual, "oops\nlast output:\n    oops")
+		So(sink.types("skipped"), ShouldResemble, []EventType{EventCommandEnd})
+		for _, e := range sink.events {
+			if e.Type == EventOutputChunk {
+				So(e.Stream, ShouldEqual, "stderr")
+			}
+		}
+	})
+
+	Convey("The JSON Lines sink writes one object per event", t, func() {
+		out := &bytes.Buffer{}
+		So(shellCmd("hello", "echo hello").Exec(WithEventSink(NewJSONLSink(out))), ShouldEqual, io.EOF)
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		So(len(lines), ShouldEqual, 1)
+		var e map[string]interface{}
+		So(json.Unmarshal([]byte(lines[0]), &e), ShouldBeNil)
+		So(e["type"], ShouldEqual, "output_chunk")
+		So(e["command"], ShouldEqual, "hello")
+		So(e["stream"], ShouldEqual, "stdout")
+		So(e["text"], ShouldEqual, "hello\n")
+		So(e, ShouldNotContainKey, "result")
+	})
+
+	Convey("Results are encoded with snake case keys", t, func() {
+		r := &Result{Name: "install", Status: StatusFailed, ExitCode: 2, Attempts: 3, Err: errors.New("boom"),
+			Start: time.Date(2016, 12, 1, 14, 52, 36, 0, time.UTC), Duration: 1500 * time.Millisecond,
+			Hooks: []*Result{{Name: "cleanup", Status: StatusOK, Hook: HookAlways}}}
+		data, err := json.Marshal(r)
+		So(err, ShouldBeNil)
+		var decoded map[string]interface{}
+		So(json.Unmarshal(data, &decoded), ShouldBeNil)
+		So(decoded["name"], ShouldEqual, "install")
+		So(decoded["status"], ShouldEqual, "failed")
+		So(decoded["exit_code"], ShouldEqual, 2)
+		So(decoded["error"], ShouldEqual, "boom")
+		So(decoded["start"], ShouldEqual, "2016-12-01T14:52:36Z")
+		So(decoded["duration"], ShouldEqual, "1.5s")
+		So(decoded["duration_seconds"], ShouldEqual, 1.5)
+		So(decoded["hooks"].([]interface{})[0].(map[string]interface{})["hook"], ShouldEqual, "always")
+	})
+
+	Convey("Sessions send events too", t, func() {
+		sink := &recordingSink{}
+		s, err := NewSession(&ExecTransport{Cmd: shellCmd("cat", "cat").Cmd}, WithEventSink(sink))
+		So(err, ShouldBeNil)
+		So(s.SendSecret("hunter2"), ShouldBeNil)
+		_, err = s.Expect("hunter", time.Second)
+		So(err, ShouldBeNil)
+		So(s.Wait(), ShouldBeNil)
+		So(sink.types(""), ShouldResemble, []EventType{EventResponseSent, EventOutputChunk, EventExpectationMatched})
+		So(sink.events[0].Text, ShouldEqual, secretMask+"\n")
+	})
+}
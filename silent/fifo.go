@@ -0,0 +1,141 @@
+package silent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FifoTransport talks to a process through a pair of named pipes. Output is read from ReadPath, and responses are
+// written to WritePath. Opening a named pipe blocks until the other side opens it too, so both are opened at once
+type FifoTransport struct {
+	ReadPath string
+	// WritePath may be empty if nothing is ever written back, in which case responses fail
+	WritePath string
+	// OpenTimeout limits how long to wait for the other side to open the pipes, zero means no limit
+	OpenTimeout time.Duration
+	r           *os.File
+	w           *os.File
+}
+
+// Start implements Transport
+func (t *FifoTransport) Start() error {
+	var timeout <-chan time.Time
+	if t.OpenTimeout > 0 {
+		timer := time.NewTimer(t.OpenTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	reader := openFifo(t.ReadPath, os.O_RDONLY)
+	var writer chan openedFifo
+	if t.WritePath != "" {
+		writer = openFifo(t.WritePath, os.O_WRONLY)
+	}
+
+	var err error
+	for reader != nil || writer != nil {
+		select {
+		case o := <-reader:
+			reader = nil
+			t.r = o.f
+			if err == nil {
+				err = o.err
+			}
+		case o := <-writer:
+			writer = nil
+			t.w = o.f
+			if err == nil {
+				err = o.err
+			}
+		case <-timeout:
+			// anything opened late is closed as soon as it is
+			closeFifoLater(reader)
+			closeFifoLater(writer)
+			reader, writer = nil, nil
+			if err == nil {
+				err = fmt.Errorf("timed out after %s waiting for the other side of %s to open", t.OpenTimeout, t.ReadPath)
+			}
+		}
+	}
+	if err != nil {
+		t.Wait()
+	}
+	return err
+}
+
+// Stdout implements Transport
+func (t *FifoTransport) Stdout() io.Reader {
+	return t.r
+}
+
+// Stderr implements Transport, named pipes have no separate error output
+func (t *FifoTransport) Stderr() io.Reader {
+	return nil
+}
+
+// Stdin implements Transport, closing it closes the write pipe so the other side sees EOF
+func (t *FifoTransport) Stdin() io.WriteCloser {
+	if t.w == nil {
+		return noWriter{}
+	}
+	return t.w
+}
+
+// Wait implements Transport, closing both pipes
+func (t *FifoTransport) Wait() error {
+	var err error
+	if t.r != nil {
+		err = t.r.Close()
+	}
+	if t.w != nil {
+		t.w.Close()
+	}
+	return err
+}
+
+// Signal implements Transport
+func (t *FifoTransport) Signal(sig os.Signal) error {
+	return ErrSignalUnsupported
+}
+
+// openedFifo is the result of opening a named pipe
+type openedFifo struct {
+	f   *os.File
+	err error
+}
+
+// openFifo opens the named pipe at path in the background
+func openFifo(path string, flag int) chan openedFifo {
+	ch := make(chan openedFifo, 1)
+	go func() {
+		f, err := os.OpenFile(path, flag, 0)
+		ch <- openedFifo{f, err}
+	}()
+	return ch
+}
+
+// closeFifoLater closes whatever ch opens, once it's opened
+func closeFifoLater(ch chan openedFifo) {
+	if ch == nil {
+		return
+	}
+	go func() {
+		if o := <-ch; o.f != nil {
+			o.f.Close()
+		}
+	}()
+}
+
+// noWriter is the stdin of a transport that can't be written to
+type noWriter struct{}
+
+func (noWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("transport has nowhere to write responses")
+}
+
+func (noWriter) Close() error {
+	return nil
+}
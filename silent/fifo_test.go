@@ -0,0 +1,60 @@
+//go:build !windows
+// +build !windows
+
+package silent
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFifoTransport(t *testing.T) {
+	Convey("Given a pair of named pipes", t, func() {
+		dir, err := ioutil.TempDir("", "silent-fifo")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		out := filepath.Join(dir, "out")
+		in := filepath.Join(dir, "in")
+		So(syscall.Mkfifo(out, 0600), ShouldBeNil)
+		So(syscall.Mkfifo(in, 0600), ShouldBeNil)
+
+		Convey("Expectations are matched against whatever is on the other end", func() {
+			go func() {
+				w, _ := os.OpenFile(out, os.O_WRONLY, 0)
+				defer w.Close()
+				r, _ := os.Open(in)
+				defer r.Close()
+				w.Write([]byte("Name? "))
+				name, _ := bufio.NewReader(r).ReadString('\n')
+				w.Write([]byte("Hello " + strings.TrimSpace(name) + "\n"))
+			}()
+
+			s := NewSilentCmd()
+			s.Connect = "fifo://" + out + "?in=" + in
+			s.Timeout.Duration = 5 * time.Second
+			s.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+			So(s.Exec(), ShouldEqual, io.EOF)
+			So(s.Output(), ShouldEqual, "Name? Hello Chris\n")
+		})
+
+		Convey("A fifo nobody opens times out", func() {
+			s := NewSilentCmd()
+			s.Connect = "fifo://" + out
+			s.Timeout.Duration = 100 * time.Millisecond
+			err := s.Exec()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "timed out")
+		})
+	})
+}
@@ -0,0 +1,55 @@
+package silent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// CheckGuards evaluates this command's creates, removes, only_if and unless guards in that order, returning the reason
+// the command should be skipped, or an empty string if it should run. An error is returned if a check command
+// could not be run at all
+func (s *SilentCmd) CheckGuards() (skip string, err error) {
+	if s.Creates != "" {
+		if _, err := os.Stat(s.Creates); err == nil {
+			return fmt.Sprintf("%s already exists", s.shown(s.Creates)), nil
+		}
+	}
+	if s.Removes != "" {
+		if _, err := os.Stat(s.Removes); os.IsNotExist(err) {
+			return fmt.Sprintf("%s does not exist", s.shown(s.Removes)), nil
+		}
+	}
+	if s.OnlyIf != "" {
+		ok, err := s.check(s.OnlyIf)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return fmt.Sprintf("only_if check %q failed", s.shown(s.OnlyIf)), nil
+		}
+	}
+	if s.Unless != "" {
+		ok, err := s.check(s.Unless)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return fmt.Sprintf("unless check %q succeeded", s.shown(s.Unless)), nil
+		}
+	}
+	return "", nil
+}
+
+// check runs cmdString, one of the command's guards, returning true if it exits successfully and false if it exits
+// with any other code
+func (s *SilentCmd) check(cmdString string) (bool, error) {
+	err := commandFromString(cmdString).Run()
+	if err == nil {
+		return true, nil
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return false, nil
+	}
+	return false, fmt.Errorf("could not run check %q: %s", s.shown(cmdString), err)
+}
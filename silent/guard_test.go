@@ -0,0 +1,99 @@
+package silent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSilentCmd_CheckGuards(t *testing.T) {
+	Convey("Given a temporary directory with a file in it", t, func() {
+		dir, err := ioutil.TempDir("", "silent-guard")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		exists := filepath.Join(dir, "exists")
+		missing := filepath.Join(dir, "missing")
+		So(ioutil.WriteFile(exists, nil, 0644), ShouldBeNil)
+
+		Convey("A command without guards always runs", func() {
+			skip, err := shellCmd("a", "true").CheckGuards()
+			So(err, ShouldBeNil)
+			So(skip, ShouldBeEmpty)
+		})
+
+		Convey("creates skips a command when its path exists", func() {
+			s := shellCmd("a", "true")
+			s.Creates = exists
+			skip, _ := s.CheckGuards()
+			So(skip, ShouldContainSubstring, "already exists")
+			s.Creates = missing
+			skip, _ = s.CheckGuards()
+			So(skip, ShouldBeEmpty)
+		})
+
+		Convey("removes skips a command when its path doesn't exist", func() {
+			s := shellCmd("a", "true")
+			s.Removes = missing
+			skip, _ := s.CheckGuards()
+			So(skip, ShouldContainSubstring, "does not exist")
+			s.Removes = exists
+			skip, _ = s.CheckGuards()
+			So(skip, ShouldBeEmpty)
+		})
+
+		Convey("only_if skips a command when its check fails", func() {
+			s := shellCmd("a", "true")
+			s.OnlyIf = "test -f " + missing
+			skip, err := s.CheckGuards()
+			So(err, ShouldBeNil)
+			So(skip, ShouldContainSubstring, "only_if")
+			s.OnlyIf = "test -f " + exists
+			skip, _ = s.CheckGuards()
+			So(skip, ShouldBeEmpty)
+		})
+
+		Convey("unless skips a command when its check succeeds", func() {
+			s := shellCmd("a", "true")
+			s.Unless = "test -f " + exists
+			skip, err := s.CheckGuards()
+			So(err, ShouldBeNil)
+			So(skip, ShouldContainSubstring, "unless")
+			s.Unless = "test -f " + missing
+			skip, _ = s.CheckGuards()
+			So(skip, ShouldBeEmpty)
+		})
+
+		Convey("A check command that can't be run is an error", func() {
+			s := shellCmd("a", "true")
+			s.OnlyIf = "/this/does/not/exist"
+			_, err := s.CheckGuards()
+			So(err, ShouldNotBeNil)
+			So(s.Run().Status, ShouldEqual, StatusFailed)
+		})
+
+		Convey("Skipped commands are reported as such, and don't hold up their dependents", func() {
+			record := filepath.Join(dir, "record")
+			guarded := recordCmd(record, "guarded")
+			guarded.Creates = exists
+			r := &Runner{Commands: SilentCmds{guarded, recordCmd(record, "after", "guarded")}}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(r.Results[0].Status, ShouldEqual, StatusSkipped)
+			So(r.Results[0].String(), ShouldContainSubstring, "skipped")
+			So(r.Results[1].Status, ShouldEqual, StatusOK)
+			So(readRecord(record), ShouldResemble, []string{"after"})
+		})
+
+		Convey("Guards are templated along with the command", func() {
+			s := shellCmd("a", "true")
+			s.Creates = "{{.DIR}}/exists"
+			So(s.ExecTemplate(map[string]string{"DIR": dir}), ShouldBeNil)
+			So(s.Creates, ShouldEqual, exists)
+		})
+	})
+}
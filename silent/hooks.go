@@ -0,0 +1,57 @@
+package silent
+
+import (
+	"os"
+	"strings"
+)
+
+// Hook kinds, as used in Result.Hook
+const (
+	HookOnFailure = "on_failure"
+	HookOnSuccess = "on_success"
+	HookAlways    = "always"
+)
+
+// runHooks runs this command's hooks for result with the options of the current run, returning their results
+func (s *SilentCmd) runHooks(result *Result) []*Result {
+	if result.Status == StatusSkipped || result.Status == StatusPending {
+		return nil
+	}
+	env := result.Environ()
+	var results []*Result
+	if result.Status == StatusFailed || result.Status == StatusIgnored {
+		results = append(results, runHooks(HookOnFailure, s.OnFailure, env, s.options())...)
+	} else {
+		results = append(results, runHooks(HookOnSuccess, s.OnSuccess, env, s.options())...)
+	}
+	return append(results, runHooks(HookAlways, s.Always, env, s.options())...)
+}
+
+// runHooks runs each of hooks in turn with env added to its environment, using the options o. A failing hook
+// doesn't stop the remaining hooks from running, and doesn't change the outcome of whatever it was hooked on to
+func runHooks(kind string, hooks SilentCmds, env []string, o *RunOptions) []*Result {
+	var results []*Result
+	for _, hook := range hooks {
+		if hook.Cmd != nil {
+			hook.Cmd.Env = hookEnviron(hook.Cmd.Env, env)
+		}
+		result := hook.run(o)
+		result.Hook = kind
+		results = append(results, result)
+	}
+	return results
+}
+
+// hookEnviron returns base with any previous hook variables replaced by env
+func hookEnviron(base, env []string) []string {
+	if base == nil {
+		base = os.Environ()
+	}
+	var environ []string
+	for _, e := range base {
+		if !strings.HasPrefix(e, "SILENT_") {
+			environ = append(environ, e)
+		}
+	}
+	return append(environ, env...)
+}
@@ -0,0 +1,115 @@
+package silent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSilentCmd_runHooks(t *testing.T) {
+	Convey("Given a temporary directory to record hooks in", t, func() {
+		dir, err := ioutil.TempDir("", "silent-hooks")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		record := filepath.Join(dir, "record")
+		hooked := func(script string) *SilentCmd {
+			s := shellCmd("main", script)
+			s.OnFailure = SilentCmds{recordCmd(record, "failure")}
+			s.OnSuccess = SilentCmds{recordCmd(record, "success")}
+			s.Always = SilentCmds{recordCmd(record, "always")}
+			return s
+		}
+
+		Convey("on_success and always hooks run after a command succeeds", func() {
+			result := hooked("true").Run()
+			So(result.Status, ShouldEqual, StatusOK)
+			So(readRecord(record), ShouldResemble, []string{"success", "always"})
+			So(len(result.Hooks), ShouldEqual, 2)
+			So(result.Hooks[0].Hook, ShouldEqual, HookOnSuccess)
+			So(result.Hooks[1].Hook, ShouldEqual, HookAlways)
+		})
+
+		Convey("on_failure and always hooks run after a command fails, once it's out of retries", func() {
+			s := hooked("false")
+			s.Retries = 2
+			result := s.Run()
+			So(result.Status, ShouldEqual, StatusFailed)
+			So(readRecord(record), ShouldResemble, []string{"failure", "always"})
+		})
+
+		Convey("No hooks run for skipped commands", func() {
+			s := hooked("true")
+			s.Unless = "true"
+			result := s.Run()
+			So(result.Status, ShouldEqual, StatusSkipped)
+			So(readRecord(record), ShouldBeEmpty)
+			So(result.Hooks, ShouldBeEmpty)
+		})
+
+		Convey("Hooks receive the command's result in their environment", func() {
+			s := shellCmd("main", "exit 4")
+			s.OnFailure = SilentCmds{shellCmd("env", "echo $SILENT_NAME $SILENT_STATUS $SILENT_EXIT_CODE > "+record)}
+			s.Run()
+			So(readRecord(record), ShouldResemble, []string{"main", "failed", "4"})
+		})
+
+		Convey("Hooks are given just the cause of a failure, not its diagnostics", func() {
+			errorHook := func(name string) SilentCmds {
+				return SilentCmds{shellCmd("env", "printf '%s|' \"$SILENT_ERROR\" >> "+filepath.Join(dir, name))}
+			}
+			s := shellCmd("main", "echo oops; exit 4")
+			s.OnFailure = errorHook("command")
+			r := &Runner{Commands: SilentCmds{s}, OnFailure: errorHook("runner")}
+			So(r.Run(), ShouldHaveSameTypeAs, &FailureError{})
+			for _, name := range []string{"command", "runner"} {
+				data, err := ioutil.ReadFile(filepath.Join(dir, name))
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "exit status 4|")
+			}
+		})
+
+		Convey("A failing hook doesn't change the command's result", func() {
+			s := shellCmd("main", "true")
+			s.OnSuccess = SilentCmds{shellCmd("broken", "false")}
+			result := s.Run()
+			So(result.Status, ShouldEqual, StatusOK)
+			So(result.Hooks[0].Status, ShouldEqual, StatusFailed)
+			So(result.Hooks[0].String(), ShouldStartWith, "on_success hook broken: failed")
+		})
+
+		Convey("Runner hooks run once every command has finished", func() {
+			r := &Runner{
+				Commands:  SilentCmds{recordCmd(record, "a"), shellCmd("b", "exit 3")},
+				OnFailure: SilentCmds{shellCmd("env", "echo failure $SILENT_NAME $SILENT_EXIT_CODE >> "+record)},
+				OnSuccess: SilentCmds{recordCmd(record, "success")},
+				Always:    SilentCmds{recordCmd(record, "always")},
+			}
+			So(r.Run(), ShouldNotEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "failure", "b", "3", "always"})
+			So(len(r.HookResults), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestConfig_hooks(t *testing.T) {
+	Convey("Hooks are loaded, templated and attached to a config's runner", t, func() {
+		os.Setenv("SILENT_HOOK_TEST", "hello")
+		cfg, err := NewConfigFromJSON([]byte(`{
+			"commands": [{"cmd": "true", "on_failure": [{"cmd": "echo {{.SILENT_HOOK_TEST}}"}]}],
+			"always": [{"cmd": "echo {{.SILENT_HOOK_TEST}} again"}]
+		}`))
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].OnFailure[0].CmdString, ShouldEqual, "echo hello")
+		So(cfg.Commands[0].OnFailure[0].Cmd, ShouldNotBeNil)
+		r := cfg.Runner()
+		So(r.Always[0].CmdString, ShouldEqual, "echo hello again")
+		So(r.Run(), ShouldEqual, io.EOF)
+		So(r.HookResults[0].Status, ShouldEqual, StatusOK)
+	})
+}
@@ -0,0 +1,144 @@
+package silent
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// htmlReport is what the HTML report template is executed with
+type htmlReport struct {
+	Title    string
+	Report   *Report
+	Commands []*htmlCommand
+}
+
+// htmlCommand is a command or hook's result, with the timeline of its conversation
+type htmlCommand struct {
+	*Result
+	Label    string
+	Timeline []*htmlEntry
+}
+
+// htmlEntry is a line of a command's timeline
+type htmlEntry struct {
+	// Offset is the time since the command started
+	Offset string
+	// Kind is what happened, and is also the entry's CSS class
+	Kind string
+	Text string
+}
+
+// WriteHTML writes the report to w as a single HTML page needing nothing else to be viewed. Each command is shown
+// with the timeline of its conversation taken from events, recorded during the run with an EventRecorder: its
+// attempts, the output it wrote, the expectations matched and the responses sent, with secrets already masked in
+// responses and in commands using secret variables
+func (rep *Report) WriteHTML(w io.Writer, title string, events []*Event) error {
+	byCommand := make(map[int][]*Event)
+	for _, e := range events {
+		if e.CommandID != 0 {
+			byCommand[e.CommandID] = append(byCommand[e.CommandID], e)
+		}
+	}
+
+	data := &htmlReport{Title: title, Report: rep}
+	var add func(r *Result)
+	add = func(r *Result) {
+		c := &htmlCommand{Result: r, Label: r.Name}
+		if r.Hook != "" {
+			c.Label = fmt.Sprintf("%s (%s hook)", r.Name, r.Hook)
+		}
+		for _, e := range byCommand[r.ID] {
+			if entry := newHTMLEntry(e, r.Start); entry != nil {
+				c.Timeline = append(c.Timeline, entry)
+			}
+		}
+		data.Commands = append(data.Commands, c)
+		for _, hook := range r.Hooks {
+			add(hook)
+		}
+	}
+	for _, r := range rep.Commands {
+		add(r)
+	}
+	for _, r := range rep.Hooks {
+		add(r)
+	}
+	return htmlTemplate.Execute(w, data)
+}
+
+// newHTMLEntry returns the timeline entry for e, or nil if e doesn't belong on a timeline
+func newHTMLEntry(e *Event, start time.Time) *htmlEntry {
+	entry := &htmlEntry{Offset: fmt.Sprintf("+%.3fs", e.Time.Sub(start).Seconds())}
+	switch e.Type {
+	case EventAttemptStart:
+		entry.Kind = "attempt"
+		entry.Text = fmt.Sprintf("attempt %d started", e.Attempt)
+		if e.Awaiting != "" {
+			entry.Text += fmt.Sprintf(", waiting for %q", e.Awaiting)
+		}
+	case EventOutputChunk:
+		entry.Kind = e.Stream
+		entry.Text = e.Text
+	case EventExpectationMatched:
+		entry.Kind = "matched"
+		entry.Text = fmt.Sprintf("matched %q", e.Expectation)
+	case EventResponseSent:
+		entry.Kind = "response"
+		entry.Text = e.Text
+	default:
+		return nil
+	}
+	return entry
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(d Duration) string { return fmt.Sprintf("%.3fs", d.Seconds()) },
+	"since":   func(d time.Duration) string { return fmt.Sprintf("%.3fs", d.Seconds()) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.2em 0.8em; vertical-align: top; }
+.summary td, .summary th { border-bottom: 1px solid #ddd; }
+.ok, .retried { color: #2a7d2a; }
+.failed { color: #c62828; }
+.ignored, .skipped, .pending { color: #8a6d00; }
+p.error { white-space: pre-wrap; font-family: monospace; }
+section { margin-top: 2em; }
+.timeline td { font-family: monospace; white-space: pre-wrap; border-bottom: 1px solid #f0f0f0; }
+.timeline .offset { color: #888; }
+tr.stderr td { color: #c62828; }
+tr.matched td { background: #fff3b0; font-weight: bold; }
+tr.response td { background: #dcedff; }
+tr.attempt td { color: #555; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="{{.Report.Status}}">Run {{.Report.Status}} in {{seconds .Report.Duration}}, started {{.Report.Start.Format "2006-01-02 15:04:05 MST"}}{{with .Report.Error}}: {{.}}{{end}}</p>
+<table class="summary">
+<tr><th>Command</th><th>Status</th><th>Duration</th><th>Exit</th><th>Attempts</th><th>Expectations</th></tr>
+{{range $i, $c := .Commands}}<tr><td><a href="#cmd-{{$i}}">{{.Label}}</a></td><td class="{{.Status}}">{{.Status}}</td><td>{{since .Duration}}</td><td>{{.ExitCode}}</td><td>{{.Attempts}}</td><td>{{.Matched}}/{{.Expectations}}</td></tr>
+{{end}}</table>
+{{range $i, $c := .Commands}}
+<section id="cmd-{{$i}}">
+<h2>{{.Label}} <span class="{{.Status}}">{{.Status}}</span></h2>
+{{with .Cmd}}<p><code>{{.}}</code></p>{{end}}
+<p>{{since .Duration}}, exit code {{.ExitCode}}, {{.Attempts}} attempt(s), {{.Matched}} of {{.Expectations}} expectation(s) matched</p>
+{{with .SkipReason}}<p class="skipped">Skipped: {{.}}</p>{{end}}
+{{with .Err}}<p class="failed error">Error: {{.}}</p>{{end}}
+{{if .ExpectationResults}}<ul>{{range .ExpectationResults}}<li class="{{if .Matched}}ok{{else}}failed{{end}}">{{if .Matched}}matched{{else}}never matched{{end}} {{printf "%q" .Input}}</li>{{end}}</ul>{{end}}
+{{if .Timeline}}<table class="timeline">
+{{range .Timeline}}<tr class="{{.Kind}}"><td class="offset">{{.Offset}}</td><td>{{.Kind}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
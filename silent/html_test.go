@@ -0,0 +1,93 @@
+package silent
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReport_WriteHTML(t *testing.T) {
+	Convey("Given a run recorded with an EventRecorder", t, func() {
+		recorder := &EventRecorder{}
+		login := shellCmd("login", "printf 'User: '; read u; printf 'Password: '; read p; echo '<b>welcome</b>'")
+		login.Expectations = []*Expectation{
+			{Input: "User:", Output: "admin"},
+			{Input: "Password:", Output: "hunter2", Secret: true},
+			{Input: "never", Output: "x"},
+		}
+		broken := shellCmd("broken", "exit 4", "login")
+		broken.Always = SilentCmds{shellCmd("cleanup", "true")}
+		r := &Runner{Commands: SilentCmds{login, broken}}
+		So(r.Run(WithEventSink(recorder)), ShouldNotEqual, io.EOF)
+
+		out := &bytes.Buffer{}
+		So(r.Report().WriteHTML(out, "install.json", recorder.Events()), ShouldBeNil)
+		page := out.String()
+
+		Convey("The page stands on its own", func() {
+			So(page, ShouldStartWith, "<!DOCTYPE html>")
+			So(page, ShouldContainSubstring, "<title>install.json</title>")
+			So(page, ShouldNotContainSubstring, "<link")
+			So(page, ShouldNotContainSubstring, "<script")
+			So(page, ShouldNotContainSubstring, "src=")
+		})
+
+		Convey("Every command and hook is shown with its timings", func() {
+			So(page, ShouldContainSubstring, `<a href="#cmd-0">login</a>`)
+			So(page, ShouldContainSubstring, `<section id="cmd-2">`)
+			So(page, ShouldContainSubstring, "cleanup (always hook)")
+			So(page, ShouldContainSubstring, "Error: exit status 4")
+			So(page, ShouldContainSubstring, "2 of 3 expectation(s) matched")
+			So(page, ShouldContainSubstring, "never matched &#34;never&#34;")
+		})
+
+		Convey("Timelines highlight matches and mask secrets", func() {
+			So(page, ShouldContainSubstring, `<tr class="matched"><td class="offset">&#43;0.`)
+			So(page, ShouldContainSubstring, "matched &#34;Password:&#34;")
+			So(page, ShouldContainSubstring, `<tr class="response">`)
+			So(page, ShouldContainSubstring, "admin\n")
+			So(page, ShouldContainSubstring, secretMask+"\n")
+			So(page, ShouldNotContainSubstring, "hunter2")
+			So(page, ShouldContainSubstring, "&lt;b&gt;welcome&lt;/b&gt;")
+			So(strings.Count(page, `<tr class="attempt">`), ShouldEqual, 3)
+		})
+	})
+
+	Convey("Commands running the same thing have timelines of their own", t, func() {
+		recorder := &EventRecorder{}
+		first, second := shellCmd("", "echo hi"), shellCmd("", "echo hi")
+		first.Always = SilentCmds{shellCmd("", "echo hi")}
+		r := &Runner{Commands: SilentCmds{first, second}}
+		So(r.Run(WithEventSink(recorder)), ShouldEqual, io.EOF)
+
+		out := &bytes.Buffer{}
+		So(r.Report().WriteHTML(out, "install.json", recorder.Events()), ShouldBeNil)
+		sections := strings.Split(out.String(), "<section")[1:]
+		So(len(sections), ShouldEqual, 3)
+		for _, section := range sections {
+			So(strings.Count(section, `<tr class="attempt">`), ShouldEqual, 1)
+			So(strings.Count(section, "hi\n"), ShouldEqual, 1)
+		}
+	})
+
+	Convey("Secret variables used in commands are masked", t, func() {
+		cfg, err := NewConfigFromJSON([]byte(`{
+			"vars": {"PW": {"secret": true, "default": "hunter2"}},
+			"commands": [{"cmd": "test {{.PW}} = x", "always": [{"cmd": "true {{.PW}}"}]}]
+		}`))
+		So(err, ShouldBeNil)
+		recorder := &EventRecorder{}
+		r := cfg.Runner()
+		So(r.Run(WithEventSink(recorder)), ShouldNotEqual, io.EOF)
+
+		out := &bytes.Buffer{}
+		So(r.Report().WriteHTML(out, "install.json", recorder.Events()), ShouldBeNil)
+		page := out.String()
+		So(page, ShouldContainSubstring, "<code>test "+secretMask+" = x</code>")
+		So(page, ShouldContainSubstring, "true "+secretMask+" (always hook)")
+		So(page, ShouldNotContainSubstring, "hunter2")
+	})
+}
@@ -0,0 +1,149 @@
+package silent
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// junitSuites is the root of a JUnit XML report
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report to w as JUnit XML, with a testsuite called name holding a testcase for each
+// command and hook. Failed commands carry their error and the tail of their output, and commands that were skipped
+// or never run are skipped. If expectations is true, each of a command's own expectations is a testcase as well,
+// failing if it was never matched
+func (rep *Report) WriteJUnit(w io.Writer, name string, expectations bool) error {
+	suite := junitSuite{Name: name, Time: junitTime(rep.Duration.Duration)}
+	if !rep.Start.IsZero() {
+		suite.Timestamp = rep.Start.UTC().Format("2006-01-02T15:04:05")
+	}
+	var add func(r *Result)
+	add = func(r *Result) {
+		cmdName := r.Name
+		if r.Hook != "" {
+			cmdName = fmt.Sprintf("%s (%s hook)", r.Name, r.Hook)
+		}
+		c := junitCase{Name: cmdName, Classname: name, Time: junitTime(r.Duration)}
+		switch r.Status {
+		case StatusFailed:
+			c.Failure = &junitFailure{Message: firstLine(r.Err.Error(), 200), Type: string(r.Status),
+				Text: junitDetails(r)}
+		case StatusSkipped:
+			c.Skipped = &junitSkipped{Message: r.SkipReason}
+		case StatusPending:
+			c.Skipped = &junitSkipped{Message: "not run"}
+		case StatusIgnored:
+			c.SystemOut = "failed, ignoring: " + junitDetails(r)
+		default:
+			c.SystemOut = r.Tail
+		}
+		suite.Cases = append(suite.Cases, c)
+
+		if expectations {
+			for _, e := range r.ExpectationResults {
+				ec := junitCase{Name: fmt.Sprintf("expects %q", e.Input), Classname: name + "." + cmdName, Time: "0"}
+				switch {
+				case c.Skipped != nil:
+					ec.Skipped = &junitSkipped{Message: c.Skipped.Message}
+				case !e.Matched:
+					ec.Failure = &junitFailure{Message: "never matched", Type: "unmatched",
+						Text: fmt.Sprintf("%q was never found in the output of %s\n\noutput:\n%s", e.Input, cmdName, r.Tail)}
+				}
+				suite.Cases = append(suite.Cases, ec)
+			}
+		}
+		for _, hook := range r.Hooks {
+			add(hook)
+		}
+	}
+	for _, r := range rep.Commands {
+		add(r)
+	}
+	for _, r := range rep.Hooks {
+		add(r)
+	}
+	if rep.Status == StatusFailed && !anyFailed(rep.Commands) {
+		// the run failed without any command failing, e.g. because of a dependency cycle
+		suite.Cases = append(suite.Cases, junitCase{Name: "run", Classname: name, Time: "0",
+			Failure: &junitFailure{Message: firstLine(rep.Error, 200), Type: string(StatusFailed), Text: rep.Error}})
+	}
+	for _, c := range suite.Cases {
+		suite.Tests++
+		if c.Failure != nil {
+			suite.Failures++
+		}
+		if c.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// anyFailed returns true if any of results failed
+func anyFailed(results []*Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// junitDetails returns r's error, exit code and attempts, followed by its output unless the error already includes it
+func junitDetails(r *Result) string {
+	details := fmt.Sprintf("%s\n\nexit code: %d, attempts: %d", r.Err, r.ExitCode, r.Attempts)
+	if _, diagnosed := r.Err.(*FailureError); !diagnosed {
+		details += "\n\noutput:\n" + r.Tail
+	}
+	return details
+}
+
+// junitTime returns d in seconds, the way JUnit reports have it
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,82 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReport_WriteJUnit(t *testing.T) {
+	Convey("Given a run with passing, failing, skipped and unrun commands", t, func() {
+		login := shellCmd("login", "printf 'User: '; read u; echo welcome")
+		login.Expectations = []*Expectation{{Input: "User:", Output: "admin"}, {Input: "Password:", Output: "x"}}
+		guarded := shellCmd("guarded", "true")
+		guarded.Creates = "/"
+		r := &Runner{
+			Commands: SilentCmds{login, guarded, shellCmd("broken", "echo '<oops>'; exit 2", "login"),
+				shellCmd("after", "true", "broken")},
+			Always: SilentCmds{shellCmd("cleanup", "true")},
+			Policy: FinishIndependent,
+		}
+		So(r.Run(), ShouldNotEqual, io.EOF)
+
+		Convey("Each command is a testcase of a single suite", func() {
+			out := &bytes.Buffer{}
+			So(r.Report().WriteJUnit(out, "install.json", false), ShouldBeNil)
+			So(out.String(), ShouldStartWith, xml.Header)
+
+			var report junitSuites
+			So(xml.Unmarshal(out.Bytes(), &report), ShouldBeNil)
+			So(len(report.Suites), ShouldEqual, 1)
+			suite := report.Suites[0]
+			So(suite.Name, ShouldEqual, "install.json")
+			So(suite.Tests, ShouldEqual, 5)
+			So(suite.Failures, ShouldEqual, 1)
+			So(suite.Skipped, ShouldEqual, 2)
+			So(suite.Timestamp, ShouldNotBeEmpty)
+
+			var names []string
+			for _, c := range suite.Cases {
+				names = append(names, c.Name)
+				So(c.Classname, ShouldEqual, "install.json")
+			}
+			So(names, ShouldResemble, []string{"login", "guarded", "broken", "after", "cleanup (always hook)"})
+			So(suite.Cases[0].Failure, ShouldBeNil)
+			So(suite.Cases[0].SystemOut, ShouldContainSubstring, "welcome")
+			So(suite.Cases[1].Skipped.Message, ShouldEqual, "/ already exists")
+			So(suite.Cases[2].Failure.Message, ShouldEqual, "exit status 2")
+			So(suite.Cases[2].Failure.Text, ShouldContainSubstring, "exit code: 2")
+			So(suite.Cases[2].Failure.Text, ShouldContainSubstring, "last output:\n    <oops>\n")
+			So(suite.Cases[3].Skipped.Message, ShouldEqual, "not run")
+		})
+
+		Convey("Expectations can be testcases too", func() {
+			out := &bytes.Buffer{}
+			So(r.Report().WriteJUnit(out, "install.json", true), ShouldBeNil)
+			var report junitSuites
+			So(xml.Unmarshal(out.Bytes(), &report), ShouldBeNil)
+			suite := report.Suites[0]
+			So(suite.Tests, ShouldEqual, 7)
+			So(suite.Failures, ShouldEqual, 2)
+			So(suite.Cases[1].Name, ShouldEqual, `expects "User:"`)
+			So(suite.Cases[1].Classname, ShouldEqual, "install.json.login")
+			So(suite.Cases[1].Failure, ShouldBeNil)
+			So(suite.Cases[2].Name, ShouldEqual, `expects "Password:"`)
+			So(suite.Cases[2].Failure.Message, ShouldEqual, "never matched")
+		})
+	})
+
+	Convey("Runs failing without a failed command have a failing testcase", t, func() {
+		r := &Runner{Commands: SilentCmds{shellCmd("a", "true")}, Only: []string{"missing"}}
+		So(r.Run(), ShouldNotEqual, io.EOF)
+		out := &bytes.Buffer{}
+		So(r.Report().WriteJUnit(out, "install.json", false), ShouldBeNil)
+		So(strings.Count(out.String(), "<testcase"), ShouldEqual, 1)
+		So(out.String(), ShouldContainSubstring, `<testsuite name="install.json" tests="1" failures="1"`)
+		So(out.String(), ShouldContainSubstring, `no command named &#34;missing&#34; to select`)
+	})
+}
@@ -0,0 +1,209 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how important a log entry is
+type Level int
+
+const (
+	// LevelTrace logs everything read from and written to commands
+	LevelTrace Level = iota
+	// LevelDebug logs matched expectations, responses and other details of each execution
+	LevelDebug
+	// LevelInfo logs commands starting and finishing
+	LevelInfo
+	// LevelWarn logs failures that were retried or ignored
+	LevelWarn
+	// LevelError logs failures
+	LevelError
+)
+
+var levelNames = []string{"trace", "debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelTrace || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel converts "trace", "debug", "info", "warn" or "error" to a Level
+func ParseLevel(level string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(level, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, must be one of %s", level, strings.Join(levelNames, ", "))
+}
+
+// LogFormat is how log entries are encoded
+type LogFormat int
+
+const (
+	// LogText writes each entry as a line of key=value pairs
+	LogText LogFormat = iota
+	// LogJSON writes each entry as a JSON object on its own line
+	LogJSON
+)
+
+// ParseLogFormat converts "text" or "json" to a LogFormat
+func ParseLogFormat(format string) (LogFormat, error) {
+	switch format {
+	case "text":
+		return LogText, nil
+	case "json":
+		return LogJSON, nil
+	}
+	return LogText, fmt.Errorf("unknown log format %q, must be text or json", format)
+}
+
+// Fields are the structured details of a log entry, such as the command it's about
+type Fields map[string]interface{}
+
+// Logger writes leveled, structured log entries. A nil *Logger discards everything, and a Logger is safe to use
+// from multiple goroutines
+type Logger struct {
+	// Level is the least important level that is written
+	Level  Level
+	Format LogFormat
+	w      io.Writer
+	fields Fields
+	// l is shared by every Logger derived from the same NewLogger, so their entries don't interleave
+	l *sync.Mutex
+}
+
+// NewLogger returns a Logger writing entries at level or above to w, encoded as format
+func NewLogger(w io.Writer, level Level, format LogFormat) *Logger {
+	return &Logger{Level: level, Format: format, w: w, l: &sync.Mutex{}}
+}
+
+// With returns a Logger adding fields to every entry, on top of any this one already adds
+func (l *Logger) With(fields Fields) *Logger {
+	if l == nil {
+		return nil
+	}
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	with := *l
+	with.fields = merged
+	return &with
+}
+
+// Enabled returns true if entries at level are written
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.Level
+}
+
+// Log writes an entry at level with msg and fields, if the level is enabled
+func (l *Logger) Log(level Level, msg string, fields Fields) {
+	if !l.Enabled(level) {
+		return
+	}
+	entry := make(Fields, len(l.fields)+len(fields)+3)
+	for k, v := range l.fields {
+		entry[k] = v
+	}
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		entry[k] = v
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	var line []byte
+	if l.Format == LogJSON {
+		var err error
+		if line, err = json.Marshal(entry); err != nil {
+			line, _ = json.Marshal(map[string]string{"time": entry["time"].(string), "level": level.String(),
+				"msg": msg, "log_error": err.Error()})
+		}
+	} else {
+		line = encodeText(entry)
+	}
+	l.l.Lock()
+	defer l.l.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+// Trace logs msg at LevelTrace
+func (l *Logger) Trace(msg string, fields Fields) { l.Log(LevelTrace, msg, fields) }
+
+// Debug logs msg at LevelDebug
+func (l *Logger) Debug(msg string, fields Fields) { l.Log(LevelDebug, msg, fields) }
+
+// Info logs msg at LevelInfo
+func (l *Logger) Info(msg string, fields Fields) { l.Log(LevelInfo, msg, fields) }
+
+// Warn logs msg at LevelWarn
+func (l *Logger) Warn(msg string, fields Fields) { l.Log(LevelWarn, msg, fields) }
+
+// Error logs msg at LevelError
+func (l *Logger) Error(msg string, fields Fields) { l.Log(LevelError, msg, fields) }
+
+// Writer returns an io.Writer logging each line written to it as an entry at level, so that a standard library
+// *log.Logger can be pointed at l
+func (l *Logger) Writer(level Level) io.Writer {
+	return levelWriter{l: l, level: level}
+}
+
+type levelWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.l.Log(w.level, line, nil)
+	}
+	return len(p), nil
+}
+
+// encodeText encodes entry as key=value pairs, starting with its time, level and message and then the rest
+// sorted by key. Values are quoted if they need to be
+func encodeText(entry Fields) []byte {
+	keys := make([]string, 0, len(entry))
+	for k := range entry {
+		if k != "time" && k != "level" && k != "msg" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{"time", "level", "msg"}, keys...)
+
+	var b bytes.Buffer
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		v := fmt.Sprint(entry[k])
+		if v == "" || strings.ContainsAny(v, " =\"\\") || strconv.Quote(v) != `"`+v+`"` {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,128 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLogger(t *testing.T) {
+	Convey("Levels and formats are parsed from their names", t, func() {
+		for i, name := range []string{"trace", "debug", "info", "warn", "error"} {
+			level, err := ParseLevel(name)
+			So(err, ShouldBeNil)
+			So(level, ShouldEqual, Level(i))
+			So(level.String(), ShouldEqual, name)
+		}
+		level, err := ParseLevel("WARN")
+		So(err, ShouldBeNil)
+		So(level, ShouldEqual, LevelWarn)
+		_, err = ParseLevel("loud")
+		So(err, ShouldNotBeNil)
+
+		format, err := ParseLogFormat("json")
+		So(err, ShouldBeNil)
+		So(format, ShouldEqual, LogJSON)
+		_, err = ParseLogFormat("xml")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Entries below the logger's level are dropped", t, func() {
+		out := &bytes.Buffer{}
+		l := NewLogger(out, LevelWarn, LogText)
+		l.Info("quiet", nil)
+		l.Warn("loud", nil)
+		So(out.String(), ShouldNotContainSubstring, "quiet")
+		So(out.String(), ShouldContainSubstring, "msg=loud")
+		So(l.Enabled(LevelError), ShouldBeTrue)
+		So(l.Enabled(LevelDebug), ShouldBeFalse)
+	})
+
+	Convey("A nil logger discards everything", t, func() {
+		var l *Logger
+		So(func() { l.With(Fields{"a": 1}).Error("nothing", nil) }, ShouldNotPanic)
+		So(l.Enabled(LevelError), ShouldBeFalse)
+	})
+
+	Convey("Text entries are key=value pairs, quoted when needed", t, func() {
+		out := &bytes.Buffer{}
+		l := NewLogger(out, LevelTrace, LogText).With(Fields{"command": "install app"})
+		l.Debug("matched", Fields{"bytes": 12, "empty": "", "error": errors.New("bad"), "delay": time.Second})
+		line := out.String()
+		So(line, ShouldStartWith, "time=")
+		So(line, ShouldEndWith, "\n")
+		So(line, ShouldContainSubstring, ` level=debug msg=matched bytes=12 command="install app" delay=1s empty="" error=bad`)
+	})
+
+	Convey("JSON entries are objects on their own line", t, func() {
+		out := &bytes.Buffer{}
+		l := NewLogger(out, LevelTrace, LogJSON).With(Fields{"command": "install"})
+		l.Info("finished", Fields{"exit_code": 0, "text": "line\n"})
+		So(strings.Count(out.String(), "\n"), ShouldEqual, 1)
+		var entry map[string]interface{}
+		So(json.Unmarshal(out.Bytes(), &entry), ShouldBeNil)
+		So(entry["level"], ShouldEqual, "info")
+		So(entry["msg"], ShouldEqual, "finished")
+		So(entry["command"], ShouldEqual, "install")
+		So(entry["exit_code"], ShouldEqual, 0)
+		So(entry["text"], ShouldEqual, "line\n")
+		_, err := time.Parse(time.RFC3339Nano, entry["time"].(string))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("With doesn't change the logger it was called on", t, func() {
+		out := &bytes.Buffer{}
+		l := NewLogger(out, LevelInfo, LogText)
+		l.With(Fields{"command": "a"})
+		l.Info("plain", nil)
+		So(out.String(), ShouldNotContainSubstring, "command")
+	})
+
+	Convey("The standard logger can be pointed at a logger", t, func() {
+		out := &bytes.Buffer{}
+		std := log.New(NewLogger(out, LevelInfo, LogText).Writer(LevelWarn), "", 0)
+		std.Println("first")
+		std.Print("second\nthird")
+		So(strings.Count(out.String(), "level=warn"), ShouldEqual, 3)
+		So(out.String(), ShouldContainSubstring, "msg=third")
+	})
+
+	Convey("Runs log what their commands are doing", t, func() {
+		out := &bytes.Buffer{}
+		s := shellCmd("greet", "printf 'Name? '; read name; echo hi $name")
+		s.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+		result := s.Run(WithLogger(NewLogger(out, LevelTrace, LogJSON)))
+		So(result.Status, ShouldEqual, StatusOK)
+
+		var msgs []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var entry map[string]interface{}
+			So(json.Unmarshal([]byte(line), &entry), ShouldBeNil)
+			So(entry["command"], ShouldEqual, "greet")
+			msgs = append(msgs, entry["msg"].(string))
+			if entry["msg"] == "matched expectation" {
+				So(entry["expectation"], ShouldEqual, "Name?")
+				So(entry["response"], ShouldEqual, "Chris")
+			}
+		}
+		So(msgs[0], ShouldEqual, "starting")
+		So(msgs, ShouldContain, "matched expectation")
+		So(msgs, ShouldContain, "read")
+		So(msgs[len(msgs)-1], ShouldEqual, "finished")
+	})
+
+	Convey("Failures are logged as errors", t, func() {
+		out := &bytes.Buffer{}
+		So(shellCmd("broken", "exit 3").Run(WithLogger(NewLogger(out, LevelError, LogText))).Status, ShouldEqual, StatusFailed)
+		So(out.String(), ShouldContainSubstring, "level=error msg=failed")
+		So(out.String(), ShouldContainSubstring, "exit_code=3")
+		So(shellCmd("ok", "true").Exec(WithLogger(NewLogger(out, LevelError, LogText))), ShouldEqual, io.EOF)
+	})
+}
@@ -0,0 +1,72 @@
+package silent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// secretMask replaces secrets wherever they would otherwise be shown
+const secretMask = "********"
+
+// Match describes where an Expectation was found in a command's output
+type Match struct {
+	// Text is the matched text
+	Text string
+	// Captures holds the text of each capture group, if the expectation is a regular expression
+	Captures []string
+	// Before is the output that preceded the match
+	Before string
+	// end is the index just after the match in the searched output
+	end int
+}
+
+// Compile compiles e.Input if e is a regular expression, returning an error if it is invalid
+func (e *Expectation) Compile() error {
+	if !e.Regexp {
+		return nil
+	}
+	re, err := regexp.Compile(e.Input)
+	if err != nil {
+		return fmt.Errorf("invalid expectation pattern %q: %s", e.Input, err)
+	}
+	e.re = re
+	return nil
+}
+
+// Find looks for e in output, returning where it was found, or nil if it wasn't.
+// Invalid regular expressions never match
+func (e *Expectation) Find(output string) *Match {
+	if !e.Regexp {
+		i := strings.Index(output, e.Input)
+		if i < 0 {
+			return nil
+		}
+		return &Match{Text: e.Input, Before: output[:i], end: i + len(e.Input)}
+	}
+
+	if e.re == nil && e.Compile() != nil {
+		return nil
+	}
+	loc := e.re.FindStringSubmatchIndex(output)
+	if loc == nil {
+		return nil
+	}
+	m := &Match{Text: output[loc[0]:loc[1]], Before: output[:loc[0]], end: loc[1]}
+	for i := 2; i < len(loc); i += 2 {
+		capture := ""
+		if loc[i] >= 0 {
+			capture = output[loc[i]:loc[i+1]]
+		}
+		m.Captures = append(m.Captures, capture)
+	}
+	return m
+}
+
+// MaskedOutput returns e.Output, unless e is a secret
+func (e *Expectation) MaskedOutput() string {
+	if e.Secret {
+		return secretMask
+	}
+	return e.Output
+}
@@ -0,0 +1,58 @@
+package silent
+
+import (
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExpectationFind(t *testing.T) {
+	Convey("Plain expectations match substrings", t, func() {
+		e := &Expectation{Input: "a.b"}
+		So(e.Compile(), ShouldBeNil)
+		So(e.Find("axb"), ShouldBeNil)
+		m := e.Find("see a.b run")
+		So(m, ShouldNotBeNil)
+		So(m.Text, ShouldEqual, "a.b")
+		So(m.Before, ShouldEqual, "see ")
+		So(m.Captures, ShouldBeEmpty)
+	})
+
+	Convey("Regular expression expectations report their capture groups", t, func() {
+		e := &Expectation{Input: `version (\d+)\.(\d+)(-rc)?`, Regexp: true}
+		So(e.Compile(), ShouldBeNil)
+		m := e.Find("installing version 1.12 now")
+		So(m, ShouldNotBeNil)
+		So(m.Text, ShouldEqual, "version 1.12")
+		So(m.Before, ShouldEqual, "installing ")
+		So(m.Captures, ShouldResemble, []string{"1", "12", ""})
+		So(e.Find("version x"), ShouldBeNil)
+	})
+
+	Convey("Invalid regular expressions are reported when compiled and never match", t, func() {
+		e := &Expectation{Input: "(", Regexp: true}
+		So(e.Compile(), ShouldNotBeNil)
+		So(e.Find("("), ShouldBeNil)
+	})
+
+	Convey("Secret outputs are masked", t, func() {
+		So((&Expectation{Output: "hunter2", Secret: true}).MaskedOutput(), ShouldEqual, secretMask)
+		So((&Expectation{Output: "admin"}).MaskedOutput(), ShouldEqual, "admin")
+	})
+
+	Convey("Configs reject invalid expectation patterns", t, func() {
+		_, err := NewConfigFromJSON([]byte(`[{"cmd": "true", "expectations": [{"input": "(", "output": "", "regex": true}]}]`))
+		So(err, ShouldNotBeNil)
+		_, err = NewConfigFromJSON([]byte(`{"global_expectations": [{"input": "[", "regex": true}], "commands": []}`))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Regular expression expectations answer prompts during a run", t, func() {
+		cfg, err := NewConfigFromJSON([]byte(`[{"cmd": "{{.GOPATH}}` + testDataPath + `/password.sh",
+			"expectations": [{"input": "Password for \\w+:", "output": "hunter2", "regex": true, "secret": true}]}]`))
+		So(err, ShouldBeNil)
+		So(cfg.Commands.Exec(), ShouldEqual, io.EOF)
+		So(cfg.Commands[0].Output(), ShouldEqual, "Password for bob: got hunter2\n")
+	})
+}
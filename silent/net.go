@@ -0,0 +1,104 @@
+package silent
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// NetTransport connects to a network address or unix socket, such as the interactive console of an appliance
+// or daemon, optionally speaking the telnet protocol
+type NetTransport struct {
+	// Network and Address are passed to net.Dial
+	Network string
+	Address string
+	// Telnet handles telnet option negotiation, and escapes and unescapes data as telnet requires
+	Telnet bool
+	// DialTimeout limits how long connecting may take, zero means no limit
+	DialTimeout time.Duration
+	conn        net.Conn
+	telnet      *telnetConn
+}
+
+// NewConnectTransport returns a Transport for a connect target, one of:
+//
+//	tcp://host:port
+//	telnet://host:port
+//	unix:///path/to/socket
+//	fifo:///path/to/output?in=/path/to/input
+//
+// dialTimeout limits how long connecting, or waiting for the other end of a fifo, may take
+func NewConnectTransport(target string, dialTimeout time.Duration) (Transport, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "telnet":
+		if u.Host == "" || u.Port() == "" {
+			return nil, fmt.Errorf("connect target %q must include a host and port", target)
+		}
+		return &NetTransport{Network: "tcp", Address: u.Host, Telnet: u.Scheme == "telnet", DialTimeout: dialTimeout}, nil
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("connect target %q must include a socket path", target)
+		}
+		return &NetTransport{Network: "unix", Address: u.Path, DialTimeout: dialTimeout}, nil
+	case "fifo":
+		if u.Path == "" {
+			return nil, fmt.Errorf("connect target %q must include a fifo path", target)
+		}
+		return &FifoTransport{ReadPath: u.Path, WritePath: u.Query().Get("in"), OpenTimeout: dialTimeout}, nil
+	}
+	return nil, fmt.Errorf("unsupported connect target %q, must be tcp://, telnet://, unix:// or fifo://", target)
+}
+
+// Start implements Transport
+func (t *NetTransport) Start() (err error) {
+	t.conn, err = net.DialTimeout(t.Network, t.Address, t.DialTimeout)
+	if err != nil {
+		return err
+	}
+	if t.Telnet {
+		t.telnet = newTelnetConn(t.conn)
+	}
+	return nil
+}
+
+// Stdout implements Transport
+func (t *NetTransport) Stdout() io.Reader {
+	if t.telnet != nil {
+		return t.telnet
+	}
+	return t.conn
+}
+
+// Stderr implements Transport, network connections have no separate error output
+func (t *NetTransport) Stderr() io.Reader {
+	return nil
+}
+
+// Stdin implements Transport. Closing it closes the write side of the connection
+func (t *NetTransport) Stdin() io.WriteCloser {
+	if t.telnet != nil {
+		return streamWriter{t.telnet}
+	}
+	return streamWriter{t.conn}
+}
+
+// Wait implements Transport, closing the connection
+func (t *NetTransport) Wait() error {
+	return t.conn.Close()
+}
+
+// Signal implements Transport. Telnet connections can be sent os.Interrupt, which is sent as telnet's
+// "interrupt process", nothing else is supported
+func (t *NetTransport) Signal(sig os.Signal) error {
+	if t.telnet != nil && sig == os.Interrupt {
+		return t.telnet.interrupt()
+	}
+	return ErrSignalUnsupported
+}
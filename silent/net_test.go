@@ -0,0 +1,178 @@
+package silent
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// listen starts a local listener that hands each connection it accepts to handle
+func listen(handle func(net.Conn)) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	So(err, ShouldBeNil)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return l
+}
+
+func TestNetTransport(t *testing.T) {
+	Convey("Given a local device console", t, func() {
+		l := listen(fakeDevice)
+		Reset(func() {
+			l.Close()
+		})
+
+		Convey("Expectations are matched over tcp", func() {
+			s := NewSilentCmd()
+			s.Connect = "tcp://" + l.Addr().String()
+			s.Expectations = []*Expectation{{Input: "login:", Output: "admin"}}
+			So(s.Exec(), ShouldEqual, io.EOF)
+			So(s.Output(), ShouldEqual, "login: Welcome admin\n")
+			So(s.DisplayName(), ShouldEqual, s.Connect)
+
+			Convey("And again, as each execution makes a new connection", func() {
+				s.Init()
+				s.Expectations = []*Expectation{{Input: "login:", Output: "root"}}
+				So(s.Exec(), ShouldEqual, io.EOF)
+				So(s.Output(), ShouldEqual, "login: Welcome root\n")
+			})
+		})
+	})
+
+	Convey("Expectations are matched over a unix socket", t, func() {
+		dir, err := ioutil.TempDir("", "silent-unix")
+		So(err, ShouldBeNil)
+		path := filepath.Join(dir, "console.sock")
+		l, err := net.Listen("unix", path)
+		So(err, ShouldBeNil)
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				fakeDevice(conn)
+			}
+		}()
+		Reset(func() {
+			l.Close()
+			os.RemoveAll(dir)
+		})
+
+		cfg, err := NewConfigFromJSON([]byte(`[{"connect": "unix://` + path + `", "timeout": "5s",
+			"expectations": [{"input": "login:", "output": "admin"}]}]`))
+		So(err, ShouldBeNil)
+		r := cfg.Runner()
+		So(r.Run(), ShouldEqual, io.EOF)
+		So(r.Results[0].Status, ShouldEqual, StatusOK)
+		So(cfg.Commands[0].Output(), ShouldEqual, "login: Welcome admin\n")
+	})
+
+	Convey("A console that never finishes times out", t, func() {
+		l := listen(func(conn net.Conn) {
+			conn.Write([]byte("> "))
+			io.Copy(conn, conn)
+		})
+		Reset(func() {
+			l.Close()
+		})
+		s := NewSilentCmd()
+		s.Connect = "tcp://" + l.Addr().String()
+		s.Timeout.Duration = 200 * time.Millisecond
+		start := time.Now()
+		err := s.Exec()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "timed out after 200ms")
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+	})
+
+	Convey("Connecting to nothing is an error", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		addr := l.Addr().String()
+		l.Close()
+		s := NewSilentCmd()
+		s.Connect = "tcp://" + addr
+		err = s.Exec()
+		So(err, ShouldNotBeNil)
+		So(err, ShouldNotEqual, io.EOF)
+	})
+
+	Convey("Telnet option negotiation is handled while matching expectations", t, func() {
+		negotiated := make(chan []byte, 1)
+		l := listen(func(conn net.Conn) {
+			defer conn.Close()
+			// WILL ECHO, DO TERMINAL-TYPE, a subnegotiation we never asked for, then a prompt with an escaped IAC
+			conn.Write([]byte{telnetIAC, telnetWILL, telnetOptEcho, telnetIAC, telnetDO, 24,
+				telnetIAC, telnetSB, 24, 1, telnetIAC, telnetSE})
+			conn.Write([]byte("login\xff\xff: "))
+			r := bufio.NewReader(conn)
+			reply := make([]byte, 6)
+			io.ReadFull(r, reply)
+			negotiated <- reply
+			name, _ := r.ReadString('\n')
+			conn.Write([]byte("Welcome " + strings.TrimSpace(name) + "\r\n"))
+		})
+		Reset(func() {
+			l.Close()
+		})
+
+		s := NewSilentCmd()
+		s.Connect = "telnet://" + l.Addr().String()
+		s.Expectations = []*Expectation{{Input: "login\xff:", Output: "admin"}}
+		So(s.Exec(), ShouldEqual, io.EOF)
+		So(<-negotiated, ShouldResemble, []byte{telnetIAC, telnetDO, telnetOptEcho, telnetIAC, telnetWONT, 24})
+		So(s.Output(), ShouldEqual, "login\xff: Welcome admin\r\n")
+	})
+}
+
+func TestNewConnectTransport(t *testing.T) {
+	Convey("Connect targets are parsed into transports", t, func() {
+		tr, err := NewConnectTransport("telnet://10.0.0.1:23", time.Second)
+		So(err, ShouldBeNil)
+		So(tr, ShouldResemble, &NetTransport{Network: "tcp", Address: "10.0.0.1:23", Telnet: true, DialTimeout: time.Second})
+
+		tr, err = NewConnectTransport("tcp://localhost:2000", 0)
+		So(err, ShouldBeNil)
+		So(tr.(*NetTransport).Telnet, ShouldBeFalse)
+
+		tr, err = NewConnectTransport("unix:///var/run/app.sock", 0)
+		So(err, ShouldBeNil)
+		So(tr, ShouldResemble, &NetTransport{Network: "unix", Address: "/var/run/app.sock"})
+
+		tr, err = NewConnectTransport("fifo:///tmp/app.out?in=/tmp/app.in", time.Second)
+		So(err, ShouldBeNil)
+		So(tr, ShouldResemble, &FifoTransport{ReadPath: "/tmp/app.out", WritePath: "/tmp/app.in", OpenTimeout: time.Second})
+
+		_, err = NewConnectTransport("tcp://localhost", 0)
+		So(err, ShouldNotBeNil)
+		_, err = NewConnectTransport("unix://", 0)
+		So(err, ShouldNotBeNil)
+		_, err = NewConnectTransport("fifo://", 0)
+		So(err, ShouldNotBeNil)
+		_, err = NewConnectTransport("ftp://localhost:21", 0)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Invalid connect targets are reported when loading a config", t, func() {
+		_, err := NewConfigFromJSON([]byte(`[{"connect": "gopher://localhost:70"}]`))
+		So(err, ShouldNotBeNil)
+
+		cfg, err := NewConfigFromJSON([]byte(`[{"connect": "telnet://localhost:23", "timeout": "30s"}]`))
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].Cmd, ShouldBeNil)
+		So(cfg.Commands[0].Timeout.Duration, ShouldEqual, 30*time.Second)
+	})
+}
@@ -0,0 +1,106 @@
+package silent
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/alistanis/silentinstall/silent/ui"
+)
+
+// Verbose turns on verbose logging for runs that don't choose for themselves with WithVerbose.
+//
+// Deprecated: use WithVerbose, which only affects the run it's given to.
+var Verbose bool
+
+// Clock tells the time and waits, so tests can control how long things appear to take
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is a Clock using the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+// RunOptions controls how commands are executed. Each run has its own, so two runs in the same process
+// don't affect each other
+type RunOptions struct {
+	// Verbose logs everything read from and written to commands to stderr, unless there is a Logger
+	Verbose bool
+	// Ui, if set, is used instead of each command's own ui
+	Ui ui.Ui
+	// Logger receives the run's log entries, if nil nothing is logged unless Verbose is set
+	Logger *Logger
+	// Clock times commands, timeouts and retry delays
+	Clock Clock
+	// Transcripts receive everything read from commands and every response written to them, with secrets masked
+	Transcripts []io.Writer
+	// Sinks receive events as the run progresses
+	Sinks []EventSink
+	// ids counts the commands started during the run, and is shared by the copies made for each of them
+	ids *int64
+}
+
+// RunOption sets one of the RunOptions
+type RunOption func(o *RunOptions)
+
+// WithVerbose sets whether a run without a logger logs everything read from and written to its commands
+func WithVerbose(verbose bool) RunOption {
+	return func(o *RunOptions) { o.Verbose = verbose }
+}
+
+// WithUi shows a run's output on u instead of each command's own ui
+func WithUi(u ui.Ui) RunOption {
+	return func(o *RunOptions) { o.Ui = u }
+}
+
+// WithLogger sends a run's log entries to l
+func WithLogger(l *Logger) RunOption {
+	return func(o *RunOptions) { o.Logger = l }
+}
+
+// WithClock times a run with c instead of the real clock
+func WithClock(c Clock) RunOption {
+	return func(o *RunOptions) { o.Clock = c }
+}
+
+// WithTranscript adds w to the writers receiving a transcript of the run
+func WithTranscript(w io.Writer) RunOption {
+	return func(o *RunOptions) { o.Transcripts = append(o.Transcripts, w) }
+}
+
+// WithEventSink adds sink to the sinks receiving the run's events
+func WithEventSink(sink EventSink) RunOption {
+	return func(o *RunOptions) { o.Sinks = append(o.Sinks, sink) }
+}
+
+// NewRunOptions returns the defaults with opts applied. Verbosity defaults to the deprecated Verbose variable
+func NewRunOptions(opts ...RunOption) *RunOptions {
+	o := &RunOptions{Verbose: Verbose, Clock: realClock{}, ids: new(int64)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Logger == nil && o.Verbose {
+		o.Logger = NewLogger(os.Stderr, LevelTrace, LogText)
+	}
+	return o
+}
+
+// nextID returns an id for a command starting with these options, unique within the run
+func (o *RunOptions) nextID() int {
+	return int(atomic.AddInt64(o.ids, 1))
+}
+
+// transcribe writes text to every transcript. Transcripts are best effort, so errors are ignored
+func (o *RunOptions) transcribe(text string) {
+	for _, w := range o.Transcripts {
+		io.WriteString(w, text)
+	}
+}
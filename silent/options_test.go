@@ -0,0 +1,116 @@
+package silent
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alistanis/silentinstall/silent/ui"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock is a Clock whose time only moves when something sleeps on it
+type fakeClock struct {
+	l      sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func TestRunOptions(t *testing.T) {
+	Convey("Runs in the same process can have different verbosity", t, func() {
+		loud, quiet := &bytes.Buffer{}, &bytes.Buffer{}
+		So(shellCmd("loud", "echo loud").Exec(WithLogger(NewLogger(loud, LevelTrace, LogText))), ShouldEqual, io.EOF)
+		So(shellCmd("quiet", "echo quiet").Exec(WithLogger(NewLogger(quiet, LevelError, LogText))), ShouldEqual, io.EOF)
+		So(loud.String(), ShouldContainSubstring, `text="loud\n"`)
+		So(quiet.String(), ShouldBeEmpty)
+	})
+
+	Convey("The deprecated Verbose variable is still the default", t, func() {
+		Verbose = true
+		defer func() { Verbose = false }()
+		So(NewRunOptions().Verbose, ShouldBeTrue)
+		So(NewRunOptions().Logger.Enabled(LevelTrace), ShouldBeTrue)
+		So(NewRunOptions(WithVerbose(false)).Logger, ShouldBeNil)
+	})
+
+	Convey("Secrets are masked in verbose logs", t, func() {
+		logged := &bytes.Buffer{}
+		s := shellCmd("secret", "printf 'Password: '; read p; echo ok")
+		s.Expectations = []*Expectation{{Input: "Password:", Output: "hunter2", Secret: true}}
+		So(s.Exec(WithLogger(NewLogger(logged, LevelTrace, LogText))), ShouldEqual, io.EOF)
+		So(logged.String(), ShouldContainSubstring, secretMask)
+		So(logged.String(), ShouldNotContainSubstring, "hunter2")
+	})
+
+	Convey("Transcripts record the conversation with secrets masked", t, func() {
+		transcript := &bytes.Buffer{}
+		s := shellCmd("login", "printf 'User: '; read u; printf 'Password: '; read p; echo welcome $u")
+		s.Expectations = []*Expectation{
+			{Input: "User:", Output: "admin"},
+			{Input: "Password:", Output: "hunter2", Secret: true},
+		}
+		So(s.Exec(WithTranscript(transcript)), ShouldEqual, io.EOF)
+		So(transcript.String(), ShouldEqual, "User: admin\nPassword: "+secretMask+"\nwelcome admin\n")
+	})
+
+	Convey("Output is shown on the ui given for the run", t, func() {
+		u := ui.BufferUi()
+		So(shellCmd("hello", "echo hello").Exec(WithUi(u)), ShouldEqual, io.EOF)
+		So(u.Writer.(*bytes.Buffer).String(), ShouldContainSubstring, "hello")
+	})
+
+	Convey("Parallel runs prefix the ui given for the run", t, func() {
+		u := ui.BufferUi()
+		r := &Runner{Parallel: 2, Commands: SilentCmds{shellCmd("one", "echo first"), shellCmd("two", "echo second")}}
+		So(r.Run(WithUi(u)), ShouldEqual, io.EOF)
+		out := u.Writer.(*bytes.Buffer).String()
+		So(out, ShouldContainSubstring, "one: first")
+		So(out, ShouldContainSubstring, "two: second")
+	})
+
+	Convey("Retry delays and durations use the run's clock", t, func() {
+		clock := &fakeClock{now: time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC)}
+		s := shellCmd("flaky", "exit 1")
+		s.Retries = 2
+		s.RetryDelay = Duration{time.Minute}
+		s.Backoff = 2
+		start := time.Now()
+		result := s.Run(WithClock(clock))
+		So(time.Since(start), ShouldBeLessThan, time.Minute)
+		So(result.Attempts, ShouldEqual, 3)
+		So(clock.sleeps, ShouldResemble, []time.Duration{time.Minute, 2 * time.Minute})
+		So(result.Start, ShouldResemble, time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC))
+		So(result.Duration, ShouldEqual, 3*time.Minute)
+	})
+
+	Convey("Sessions take options too", t, func() {
+		transcript := &bytes.Buffer{}
+		s, err := NewSession(&ExecTransport{Cmd: shellCmd("cat", "cat").Cmd}, WithTranscript(transcript))
+		So(err, ShouldBeNil)
+		So(s.SendLine("hi"), ShouldBeNil)
+		So(s.SendSecret("hunter2"), ShouldBeNil)
+		So(s.Wait(), ShouldBeNil)
+		So(strings.Count(transcript.String(), "hi\n"), ShouldEqual, 2)
+		So(transcript.String(), ShouldContainSubstring, secretMask)
+	})
+}
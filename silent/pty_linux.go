@@ -0,0 +1,137 @@
+//go:build linux
+// +build linux
+
+package silent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// PtyTransport runs an *exec.Cmd attached to a new pseudo terminal, for programs that behave differently
+// (or refuse to run) when they aren't talking to a terminal. Everything the command prints, including
+// what it writes to stderr and the terminal's echo of responses, arrives on Stdout
+type PtyTransport struct {
+	Cmd    *exec.Cmd
+	master *os.File
+}
+
+// Start implements Transport
+func (t *PtyTransport) Start() error {
+	master, slave, err := openPty()
+	if err != nil {
+		return err
+	}
+	t.Cmd.Stdin = slave
+	t.Cmd.Stdout = slave
+	t.Cmd.Stderr = slave
+	if t.Cmd.SysProcAttr == nil {
+		t.Cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	t.Cmd.SysProcAttr.Setsid = true
+	t.Cmd.SysProcAttr.Setctty = true
+	err = t.Cmd.Start()
+	// the child has its own copy of the slave now
+	slave.Close()
+	if err != nil {
+		master.Close()
+		return err
+	}
+	t.master = master
+	return nil
+}
+
+// Stdout implements Transport
+func (t *PtyTransport) Stdout() io.Reader {
+	return ptyReader{t.master}
+}
+
+// Stderr implements Transport, a pty has no separate error output
+func (t *PtyTransport) Stderr() io.Reader {
+	return nil
+}
+
+// Stdin implements Transport. Closing it sends the terminal's end of file character rather than closing the pty
+func (t *PtyTransport) Stdin() io.WriteCloser {
+	return ptyWriter{t.master}
+}
+
+// Wait implements Transport
+func (t *PtyTransport) Wait() error {
+	err := t.Cmd.Wait()
+	t.master.Close()
+	return err
+}
+
+// Signal implements Transport
+func (t *PtyTransport) Signal(sig os.Signal) error {
+	if t.Cmd.Process == nil {
+		return errors.New("process has not been started")
+	}
+	return t.Cmd.Process.Signal(sig)
+}
+
+// ptyReader reads from a pty master, turning the EIO returned once the other side is closed into io.EOF
+type ptyReader struct {
+	f *os.File
+}
+
+func (r ptyReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EIO {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// ptyWriter writes to a pty master
+type ptyWriter struct {
+	f *os.File
+}
+
+func (w ptyWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+func (w ptyWriter) Close() error {
+	// ^D
+	_, err := w.f.Write([]byte{4})
+	return err
+}
+
+// openPty opens a new pseudo terminal, returning its master and slave ends
+func openPty() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unlock int32
+	if err = ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	var n uint32
+	if err = ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
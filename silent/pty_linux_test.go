@@ -0,0 +1,38 @@
+package silent
+
+import (
+	"io"
+	"os/exec"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPtyTransport(t *testing.T) {
+	Convey("Commands can be run attached to a pseudo terminal", t, func() {
+		s := NewSilentCmd()
+		s.Pty = true
+		s.Cmd = exec.Command("sh", "-c", "test -t 0 && test -t 1 && printf 'Name?'; read name; echo hi $name >&2")
+		s.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+		So(s.Exec(), ShouldEqual, io.EOF)
+		So(s.ExitCode(), ShouldEqual, 0)
+		// stderr arrives on the terminal too, after the terminal's echo of our response
+		So(s.Output(), ShouldStartWith, "Name?")
+		So(s.Output(), ShouldEndWith, "hi Chris\r\n")
+
+		Convey("And run again once re-initialized", func() {
+			s.Init()
+			s.Expectations = []*Expectation{{Input: "Name?", Output: "Again"}}
+			So(s.Exec(), ShouldEqual, io.EOF)
+			So(s.Output(), ShouldEndWith, "hi Again\r\n")
+		})
+	})
+
+	Convey("A failing command's exit code is reported through a pty", t, func() {
+		s := NewSilentCmd()
+		s.Pty = true
+		s.Cmd = exec.Command("sh", "-c", "exit 5")
+		So(s.Exec(), ShouldNotEqual, io.EOF)
+		So(s.ExitCode(), ShouldEqual, 5)
+	})
+}
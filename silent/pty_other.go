@@ -0,0 +1,49 @@
+//go:build !linux
+// +build !linux
+
+package silent
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+)
+
+var errPtyUnsupported = errors.New("pty transport is only supported on linux")
+
+// PtyTransport runs an *exec.Cmd attached to a new pseudo terminal. It is only supported on linux,
+// everywhere else Start returns an error
+type PtyTransport struct {
+	Cmd *exec.Cmd
+}
+
+// Start implements Transport
+func (t *PtyTransport) Start() error {
+	return errPtyUnsupported
+}
+
+// Stdout implements Transport
+func (t *PtyTransport) Stdout() io.Reader {
+	return nil
+}
+
+// Stderr implements Transport
+func (t *PtyTransport) Stderr() io.Reader {
+	return nil
+}
+
+// Stdin implements Transport
+func (t *PtyTransport) Stdin() io.WriteCloser {
+	return nil
+}
+
+// Wait implements Transport
+func (t *PtyTransport) Wait() error {
+	return errPtyUnsupported
+}
+
+// Signal implements Transport
+func (t *PtyTransport) Signal(sig os.Signal) error {
+	return errPtyUnsupported
+}
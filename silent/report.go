@@ -0,0 +1,112 @@
+package silent
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ReportVersion is the version of the Report format. It changes only if existing fields change meaning or are
+// removed, new fields may be added to any version
+const ReportVersion = 1
+
+// Report describes a whole run, as a stable JSON document for archiving alongside whatever the run built
+type Report struct {
+	Version int    `json:"version"`
+	Status  Status `json:"status"`
+	// Error is the first error encountered, if the run failed
+	Error           string    `json:"error,omitempty"`
+	Start           time.Time `json:"start"`
+	Duration        Duration  `json:"duration"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// Commands are the results of every command in the order they were declared, with their hooks
+	Commands []*Result `json:"commands"`
+	// Hooks are the results of the runner's own hooks
+	Hooks []*Result `json:"hooks,omitempty"`
+}
+
+// Report returns a Report of the runner's last run
+func (r *Runner) Report() *Report {
+	rep := &Report{
+		Version:         ReportVersion,
+		Status:          StatusOK,
+		Start:           r.Start,
+		Duration:        Duration{r.Duration},
+		DurationSeconds: r.Duration.Seconds(),
+		Commands:        r.Results,
+		Hooks:           r.HookResults,
+	}
+	if rep.Commands == nil {
+		rep.Commands = []*Result{}
+	}
+	if r.Err != nil {
+		rep.Status = StatusFailed
+		rep.Error = failureCause(r.Err).Error()
+	}
+	return rep
+}
+
+// Table returns the report's results as a table with a row for each command and hook: its status, duration,
+// exit code, attempts, how many of its expectations were matched and the first line of its error
+func (rep *Report) Table() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND\tSTATUS\tDURATION\tEXIT\tATTEMPTS\tEXPECTATIONS\tERROR")
+	var row func(r *Result)
+	row = func(r *Result) {
+		name := r.Name
+		if r.Hook != "" {
+			name = fmt.Sprintf("%s (%s hook)", r.Name, r.Hook)
+		}
+		status, duration, exit := string(r.Status), (r.Duration - r.Duration%time.Millisecond).String(), "-"
+		if r.Status == StatusPending {
+			status, duration = "not run", "-"
+		}
+		if r.ExitCode >= 0 {
+			exit = fmt.Sprint(r.ExitCode)
+		}
+		problem := r.SkipReason
+		if r.Err != nil {
+			problem = firstLine(r.Err.Error(), 60)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d/%d\t%s\n", name, status, duration, exit, r.Attempts, r.Matched,
+			r.Expectations, problem)
+		for _, hook := range r.Hooks {
+			row(hook)
+		}
+	}
+	for _, r := range rep.Commands {
+		row(r)
+	}
+	for _, r := range rep.Hooks {
+		row(r)
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// tailLines is the number of lines of output kept in a Result's Tail
+const tailLines = 20
+
+// tail returns the last n lines of s
+func tail(s string, n int) string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
+}
+
+// firstLine returns the first line of s with anything that isn't printable dropped, cut to at most max characters
+func firstLine(s string, max int) string {
+	l := printable(strings.SplitN(strings.TrimSpace(s), "\n", 2)[0])
+	if runes := []rune(l); len(runes) > max {
+		l = string(runes[:max-3]) + "..."
+	}
+	return l
+}
@@ -0,0 +1,105 @@
+package silent
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunner_Report(t *testing.T) {
+	Convey("A report describes every command of a run", t, func() {
+		login := shellCmd("login", "printf 'User: '; read u; echo welcome")
+		login.Expectations = []*Expectation{{Input: "User:", Output: "admin"}, {Input: "Password:", Output: "x"}}
+		broken := shellCmd("broken", "echo oops; exit 1", "login")
+		broken.IgnoreErrors = true
+		r := &Runner{
+			Commands: SilentCmds{login, broken, shellCmd("skipped", "true")},
+			Skip:     []string{"skipped"},
+			Always:   SilentCmds{shellCmd("cleanup", "true")},
+		}
+		So(r.Run(), ShouldEqual, io.EOF)
+
+		rep := r.Report()
+		So(rep.Version, ShouldEqual, ReportVersion)
+		So(rep.Status, ShouldEqual, StatusOK)
+		So(rep.Error, ShouldBeEmpty)
+		So(rep.Start, ShouldResemble, r.Start)
+		So(rep.Duration.Duration, ShouldBeGreaterThan, 0)
+		So(len(rep.Commands), ShouldEqual, 3)
+		So(rep.Commands[0].Expectations, ShouldEqual, 2)
+		So(rep.Commands[0].Matched, ShouldEqual, 1)
+		So(rep.Commands[1].Status, ShouldEqual, StatusIgnored)
+		So(rep.Commands[2].Status, ShouldEqual, StatusSkipped)
+		So(rep.Hooks[0].Name, ShouldEqual, "cleanup")
+
+		Convey("As a table", func() {
+			lines := strings.Split(rep.Table(), "\n")
+			So(len(lines), ShouldEqual, 5)
+			So(strings.Fields(lines[0]), ShouldResemble,
+				[]string{"COMMAND", "STATUS", "DURATION", "EXIT", "ATTEMPTS", "EXPECTATIONS", "ERROR"})
+			So(strings.Fields(lines[1])[0:2], ShouldResemble, []string{"login", "ok"})
+			So(strings.Fields(lines[1])[3:], ShouldResemble, []string{"0", "1", "1/2"})
+			So(strings.Fields(lines[2])[3:], ShouldResemble, []string{"1", "1", "0/0", "exit", "status", "1"})
+			So(strings.Fields(lines[3]), ShouldResemble, []string{"skipped", "skipped", "0s", "-", "0", "0/0", "deselected"})
+			So(lines[4], ShouldStartWith, "cleanup (always hook)  ok")
+		})
+
+		Convey("As JSON", func() {
+			data, err := json.Marshal(rep)
+			So(err, ShouldBeNil)
+			var decoded map[string]interface{}
+			So(json.Unmarshal(data, &decoded), ShouldBeNil)
+			So(decoded["version"], ShouldEqual, 1)
+			So(decoded["status"], ShouldEqual, "ok")
+			So(decoded, ShouldNotContainKey, "error")
+			So(decoded, ShouldContainKey, "duration_seconds")
+			commands := decoded["commands"].([]interface{})
+			So(commands[0].(map[string]interface{})["expectations"], ShouldEqual, 2)
+			So(commands[0].(map[string]interface{})["expectations_matched"], ShouldEqual, 1)
+			So(commands[1].(map[string]interface{})["error"], ShouldEqual, "exit status 1")
+			So(commands[1].(map[string]interface{})["output_tail"], ShouldEqual, "oops\n")
+			So(commands[0].(map[string]interface{})["expectation_results"], ShouldResemble, []interface{}{
+				map[string]interface{}{"input": "User:", "matched": true},
+				map[string]interface{}{"input": "Password:", "matched": false},
+			})
+			So(len(decoded["hooks"].([]interface{})), ShouldEqual, 1)
+		})
+	})
+
+	Convey("Failed runs and commands that never ran are reported", t, func() {
+		r := &Runner{Commands: SilentCmds{shellCmd("broken", "echo oops; exit 3"), shellCmd("after", "true", "broken")}}
+		So(r.Run(), ShouldNotEqual, io.EOF)
+		So(r.Err, ShouldHaveSameTypeAs, &FailureError{})
+		rep := r.Report()
+		So(rep.Status, ShouldEqual, StatusFailed)
+		// the diagnostics are in the command's own fields
+		So(rep.Error, ShouldEqual, "exit status 3")
+		lines := strings.Split(rep.Table(), "\n")
+		So(strings.Fields(lines[1])[3:], ShouldResemble, []string{"3", "1", "0/0", "exit", "status", "3"})
+		So(strings.Fields(lines[2]), ShouldResemble, []string{"after", "not", "run", "-", "-", "0", "0/0"})
+	})
+
+	Convey("Runs that can't start are reported as failed", t, func() {
+		r := &Runner{Commands: SilentCmds{shellCmd("a", "true")}, Only: []string{"missing"}}
+		So(r.Run(), ShouldNotEqual, io.EOF)
+		rep := r.Report()
+		So(rep.Status, ShouldEqual, StatusFailed)
+		So(rep.Commands, ShouldBeEmpty)
+		data, err := json.Marshal(rep)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldContainSubstring, `"commands":[]`)
+	})
+
+	Convey("Only the first line of long errors is shown in tables", t, func() {
+		rep := &Report{Commands: []*Result{{Name: "a", Status: StatusFailed, ExitCode: -1,
+			Err: errors.New(strings.Repeat("x", 100) + "\nsecond line"), Duration: 1500 * time.Microsecond}}}
+		row := strings.Split(rep.Table(), "\n")[1]
+		So(row, ShouldContainSubstring, " 1ms ")
+		So(row, ShouldEndWith, strings.Repeat("x", 57)+"...")
+	})
+}
@@ -0,0 +1,172 @@
+package silent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Status describes how a command's execution turned out
+type Status string
+
+const (
+	// StatusPending is the status of a command that was never run
+	StatusPending Status = "pending"
+	// StatusOK is the status of a command that succeeded on its first attempt
+	StatusOK Status = "ok"
+	// StatusRetried is the status of a command that succeeded after being retried
+	StatusRetried Status = "retried"
+	// StatusFailed is the status of a command that failed every attempt
+	StatusFailed Status = "failed"
+	// StatusIgnored is the status of a command that failed, but has IgnoreErrors set
+	StatusIgnored Status = "ignored"
+	// StatusSkipped is the status of a command that was not run because of one of its guards
+	StatusSkipped Status = "skipped"
+)
+
+// Result records the outcome of running a SilentCmd
+type Result struct {
+	// ID is the CommandID of the events sent about the command, or 0 if it never started
+	ID     int
+	Name   string
+	Cmd    string
+	Status Status
+	// ExitCode is the exit code of the last attempt, or -1 if the command never exited
+	ExitCode int
+	Attempts int
+	// Expectations is the number of the command's own expectations, and Matched how many of them were matched
+	// by its last attempt. Global expectations aren't counted
+	Expectations int
+	Matched      int
+	// ExpectationResults say which of the command's own expectations its last attempt matched, in the order
+	// they were declared
+	ExpectationResults []*ExpectationResult
+	// Tail is the last few lines of the last attempt's output
+	Tail string
+	// Err is the error from the last attempt, if it failed
+	Err error
+	// SkipReason explains which guard caused the command to be skipped
+	SkipReason string
+	Start      time.Time
+	Duration   time.Duration
+	// Hook is the kind of hook (on_failure, on_success or always) this is the result of, if any
+	Hook string
+	// Hooks are the results of the hooks run after the command
+	Hooks []*Result
+}
+
+// ExpectationResult says whether an expectation was matched
+type ExpectationResult struct {
+	Input   string `json:"input"`
+	Matched bool   `json:"matched"`
+}
+
+// NewResult returns a pending Result for s
+func NewResult(s *SilentCmd) *Result {
+	declared := s.declared
+	if declared == nil {
+		declared = s.Expectations
+	}
+	return &Result{
+		Name:         s.DisplayName(),
+		Cmd:          s.shown(s.CmdString),
+		Status:       StatusPending,
+		ExitCode:     -1,
+		Expectations: len(declared),
+	}
+}
+
+// Succeeded returns true if the command this result is for completed successfully, was skipped by a guard,
+// or failed but was allowed to
+func (r *Result) Succeeded() bool {
+	switch r.Status {
+	case StatusOK, StatusRetried, StatusIgnored, StatusSkipped:
+		return true
+	}
+	return false
+}
+
+// Environ returns r as environment variables for hooks
+func (r *Result) Environ() []string {
+	errString := ""
+	if r.Err != nil {
+		errString = failureCause(r.Err).Error()
+	}
+	return []string{
+		"SILENT_NAME=" + r.Name,
+		"SILENT_CMD=" + r.Cmd,
+		"SILENT_STATUS=" + string(r.Status),
+		"SILENT_EXIT_CODE=" + strconv.Itoa(r.ExitCode),
+		"SILENT_ATTEMPTS=" + strconv.Itoa(r.Attempts),
+		"SILENT_ERROR=" + errString,
+		"SILENT_DURATION=" + r.Duration.String(),
+	}
+}
+
+// String returns a one line summary of r
+func (r *Result) String() string {
+	if r.Hook != "" {
+		hook := *r
+		hook.Hook = ""
+		return r.Hook + " hook " + hook.String()
+	}
+	switch r.Status {
+	case StatusSkipped:
+		return fmt.Sprintf("%s: %s (%s)", r.Name, r.Status, r.SkipReason)
+	case StatusFailed, StatusIgnored:
+		return fmt.Sprintf("%s: %s after %d attempt(s): %s", r.Name, r.Status, r.Attempts, r.Err)
+	case StatusPending:
+		return fmt.Sprintf("%s: not run", r.Name)
+	}
+	return fmt.Sprintf("%s: %s in %s", r.Name, r.Status, r.Duration)
+}
+
+// resultJSON is how a Result is encoded as JSON
+type resultJSON struct {
+	ID           int    `json:"command_id,omitempty"`
+	Name         string `json:"name"`
+	Cmd          string `json:"cmd,omitempty"`
+	Status       Status `json:"status"`
+	ExitCode     int    `json:"exit_code"`
+	Attempts     int    `json:"attempts"`
+	Expectations int    `json:"expectations"`
+	Matched      int    `json:"expectations_matched"`
+	// ExpectationResults is named for what it holds, as "expectations" is already the count
+	ExpectationResults []*ExpectationResult `json:"expectation_results,omitempty"`
+	Tail               string               `json:"output_tail,omitempty"`
+	Error              string               `json:"error,omitempty"`
+	SkipReason         string               `json:"skip_reason,omitempty"`
+	Start              time.Time            `json:"start"`
+	Duration           Duration             `json:"duration"`
+	DurationSeconds    float64              `json:"duration_seconds"`
+	Hook               string               `json:"hook,omitempty"`
+	Hooks              []*Result            `json:"hooks,omitempty"`
+}
+
+// MarshalJSON encodes r with snake case keys, its error as a string and its duration both as a string
+// and in seconds. Errors are encoded without a FailureError's diagnostics, which are already fields of their own
+func (r *Result) MarshalJSON() ([]byte, error) {
+	j := resultJSON{
+		ID:                 r.ID,
+		Name:               r.Name,
+		Cmd:                r.Cmd,
+		Status:             r.Status,
+		ExitCode:           r.ExitCode,
+		Attempts:           r.Attempts,
+		Expectations:       r.Expectations,
+		Matched:            r.Matched,
+		ExpectationResults: r.ExpectationResults,
+		Tail:               r.Tail,
+		SkipReason:         r.SkipReason,
+		Start:              r.Start,
+		Duration:           Duration{r.Duration},
+		DurationSeconds:    r.Duration.Seconds(),
+		Hook:               r.Hook,
+		Hooks:              r.Hooks,
+	}
+	if r.Err != nil {
+		j.Error = failureCause(r.Err).Error()
+	}
+	return json.Marshal(j)
+}
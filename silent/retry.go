@@ -0,0 +1,51 @@
+package silent
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RetryOn limits which failures a command is retried for
+type RetryOn struct {
+	// ExitCodes retries the command if it exits with any of these codes
+	ExitCodes []int `json:"exit_codes"`
+	// Output retries the command if anything it printed matches any of these regular expressions
+	Output   []string `json:"output"`
+	patterns []*regexp.Regexp
+}
+
+// Compile compiles r.Output, returning an error if any of them is not a valid regular expression
+func (r *RetryOn) Compile() error {
+	r.patterns = nil
+	for _, o := range r.Output {
+		p, err := regexp.Compile(o)
+		if err != nil {
+			return fmt.Errorf("invalid retry_on output pattern %q: %s", o, err)
+		}
+		r.patterns = append(r.patterns, p)
+	}
+	return nil
+}
+
+// Matches returns true if a failure with exitCode and output should be retried
+func (r *RetryOn) Matches(exitCode int, output string) bool {
+	if r == nil || (len(r.ExitCodes) == 0 && len(r.Output) == 0) {
+		return true
+	}
+	for _, code := range r.ExitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	if len(r.patterns) != len(r.Output) {
+		if err := r.Compile(); err != nil {
+			return false
+		}
+	}
+	for _, p := range r.patterns {
+		if p.MatchString(output) {
+			return true
+		}
+	}
+	return false
+}
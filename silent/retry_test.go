@@ -0,0 +1,134 @@
+package silent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flakyCmd returns a command that fails until it has been run succeedOn times, counting its runs in the file at path.
+// If prompt is set, it is printed and a line is read before the command decides whether to fail
+func flakyCmd(path string, succeedOn int, prompt string) *SilentCmd {
+	script := "n=$(cat " + path + " 2>/dev/null || echo 0); n=$((n+1)); echo $n > " + path + "; "
+	if prompt != "" {
+		script += "printf '" + prompt + "\\n'; read answer; "
+	}
+	script += "echo attempt $n; [ $n -ge " + string('0'+rune(succeedOn)) + " ]"
+	return shellCmd("flaky", script)
+}
+
+func TestSilentCmd_Run(t *testing.T) {
+	Convey("Given a temporary directory to count attempts in", t, func() {
+		dir, err := ioutil.TempDir("", "silent-retry")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		counter := filepath.Join(dir, "counter")
+
+		Convey("A command that succeeds straight away is ok", func() {
+			result := shellCmd("ok", "true").Run()
+			So(result.Status, ShouldEqual, StatusOK)
+			So(result.Attempts, ShouldEqual, 1)
+			So(result.ExitCode, ShouldEqual, 0)
+			So(result.Err, ShouldBeNil)
+		})
+
+		Convey("A failing exit code fails the command", func() {
+			result := shellCmd("exit", "exit 3").Run()
+			So(result.Status, ShouldEqual, StatusFailed)
+			So(result.ExitCode, ShouldEqual, 3)
+			So(result.Err, ShouldNotBeNil)
+		})
+
+		Convey("A command is retried until it succeeds", func() {
+			s := flakyCmd(counter, 3, "")
+			s.Retries = 2
+			result := s.Run()
+			So(result.Status, ShouldEqual, StatusRetried)
+			So(result.Attempts, ShouldEqual, 3)
+			So(result.Succeeded(), ShouldBeTrue)
+		})
+
+		Convey("A command fails when it runs out of retries", func() {
+			s := flakyCmd(counter, 3, "")
+			s.Retries = 1
+			result := s.Run()
+			So(result.Status, ShouldEqual, StatusFailed)
+			So(result.Attempts, ShouldEqual, 2)
+			So(result.ExitCode, ShouldEqual, 1)
+		})
+
+		Convey("Expectations are restored for every attempt", func() {
+			s := flakyCmd(counter, 2, "Name?")
+			s.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+			s.Retries = 1
+			result := s.Run()
+			So(result.Status, ShouldEqual, StatusRetried)
+			So(len(s.Expectations), ShouldEqual, 0)
+			So(s.Output(), ShouldContainSubstring, "attempt 2")
+			So(s.Output(), ShouldNotContainSubstring, "attempt 1")
+		})
+
+		Convey("Retry delays back off exponentially", func() {
+			s := shellCmd("fail", "false")
+			s.Retries = 2
+			s.RetryDelay.Duration = 100 * time.Millisecond
+			s.Backoff = 2
+			start := time.Now()
+			result := s.Run()
+			So(result.Attempts, ShouldEqual, 3)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 300*time.Millisecond)
+		})
+
+		Convey("Only failures matching retry_on are retried", func() {
+			s := shellCmd("fail", "echo connection reset by peer; exit 7")
+			s.Retries = 3
+
+			s.RetryOn = &RetryOn{ExitCodes: []int{75}}
+			So(s.Run().Attempts, ShouldEqual, 1)
+
+			s.Init()
+			s.RetryOn = &RetryOn{ExitCodes: []int{7}}
+			So(s.Run().Attempts, ShouldEqual, 4)
+
+			s.Init()
+			s.RetryOn = &RetryOn{Output: []string{"connection (reset|refused)"}}
+			So(s.Run().Attempts, ShouldEqual, 4)
+		})
+
+		Convey("Ignored errors let dependents run", func() {
+			ignored := shellCmd("ignored", "false")
+			ignored.IgnoreErrors = true
+			r := &Runner{Commands: SilentCmds{ignored, shellCmd("after", "true", "ignored")}}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(r.Results[0].Status, ShouldEqual, StatusIgnored)
+			So(r.Results[1].Status, ShouldEqual, StatusOK)
+		})
+	})
+}
+
+func TestRetryOn_Compile(t *testing.T) {
+	Convey("Invalid retry_on patterns are reported when loading a config", t, func() {
+		_, err := NewConfigFromJSON([]byte(`[{"cmd": "true", "retries": 1, "retry_on": {"output": ["("]}}]`))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Retry settings are loaded from a config", t, func() {
+		cfg, err := NewConfigFromJSON([]byte(`[{"cmd": "true", "retries": 2, "retry_delay": "1m", "backoff": 2,
+			"retry_on": {"exit_codes": [1], "output": ["timed out"]}, "ignore_errors": true}]`))
+		So(err, ShouldBeNil)
+		c := cfg.Commands[0]
+		So(c.Retries, ShouldEqual, 2)
+		So(c.RetryDelay.Duration, ShouldEqual, time.Minute)
+		So(c.Backoff, ShouldEqual, 2)
+		So(c.RetryOn.ExitCodes, ShouldResemble, []int{1})
+		So(c.RetryOn.Matches(2, "the download timed out"), ShouldBeTrue)
+		So(c.IgnoreErrors, ShouldBeTrue)
+	})
+}
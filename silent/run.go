@@ -0,0 +1,473 @@
+package silent
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alistanis/silentinstall/silent/ui"
+)
+
+// Run checks this command's guards, then executes it with the given options until it succeeds or runs out of
+// retries, re-initializing it before each attempt, and finally runs its hooks. The returned Result describes the
+// final attempt
+func (s *SilentCmd) Run(opts ...RunOption) *Result {
+	return s.run(NewRunOptions(opts...))
+}
+
+// run is Run with its options already applied
+func (s *SilentCmd) run(o *RunOptions) *Result {
+	s.opts = o
+	s.attempts = 0
+	s.id = o.nextID()
+	result := NewResult(s)
+	result.ID = s.id
+	result.Start = o.Clock.Now()
+	o.emit(&Event{Type: EventCommandStart, Command: result.Name, CommandID: result.ID})
+
+	l := s.logger()
+	skip, err := s.CheckGuards()
+	if skip != "" {
+		result.Status = StatusSkipped
+		result.SkipReason = skip
+		result.Duration = o.Clock.Now().Sub(result.Start)
+		l.Info("skipped", Fields{"reason": skip})
+		o.emit(&Event{Type: EventCommandEnd, Command: result.Name, CommandID: result.ID, Result: result})
+		return result
+	}
+	if err == nil {
+		err = s.attempt(result)
+	}
+	if err != nil {
+		result.Err = err
+		result.Status = StatusFailed
+		if s.IgnoreErrors {
+			s.ui().Say(fmt.Sprintf("%s failed: %s, ignoring", s.DisplayName(), err))
+			result.Status = StatusIgnored
+		}
+	}
+	result.Duration = o.Clock.Now().Sub(result.Start)
+	fields := Fields{"status": result.Status, "attempts": result.Attempts, "exit_code": result.ExitCode,
+		"duration": result.Duration}
+	switch result.Status {
+	case StatusFailed:
+		fields["error"] = err
+		l.Error("failed", fields)
+	case StatusIgnored:
+		fields["error"] = err
+		l.Warn("failed, ignoring", fields)
+	default:
+		l.Info("finished", fields)
+	}
+	result.Hooks = s.runHooks(result)
+	o.emit(&Event{Type: EventCommandEnd, Command: result.Name, CommandID: result.ID, Attempt: result.Attempts,
+		Result: result})
+	return result
+}
+
+// attempt executes this command until it succeeds or runs out of retries, recording each attempt in result.
+// The command is re-initialized before every attempt, so a command may be Run more than once
+func (s *SilentCmd) attempt(result *Result) error {
+	if s.declared == nil {
+		s.declared = append([]*Expectation(nil), s.Expectations...)
+	}
+	delay := s.RetryDelay.Duration
+	l := s.logger()
+	for {
+		result.Attempts++
+		s.attempts = result.Attempts
+		l.Info("starting", Fields{"attempt": result.Attempts})
+		s.Init()
+		s.opts.emit(&Event{Type: EventAttemptStart, Command: result.Name, CommandID: result.ID,
+			Attempt: result.Attempts, Awaiting: s.awaiting()})
+		err := s.exec()
+		result.ExitCode = s.exitCode
+		s.recordExpectations(result)
+		result.Tail = tail(s.Output(), tailLines)
+		if err == nil || err == io.EOF {
+			result.Status = StatusOK
+			if result.Attempts > 1 {
+				result.Status = StatusRetried
+			}
+			return nil
+		}
+
+		// retries are announced with just the cause, the diagnostics are for when the command finally fails
+		cause := failureCause(err)
+		if result.Attempts > s.Retries || !s.RetryOn.Matches(s.exitCode, s.Output()+cause.Error()) {
+			return err
+		}
+		s.ui().Say(fmt.Sprintf("%s failed: %s, retrying in %s (attempt %d of %d)",
+			s.DisplayName(), cause, delay, result.Attempts+1, s.Retries+1))
+		l.Warn("failed, retrying", Fields{"error": cause, "exit_code": s.exitCode, "attempt": result.Attempts,
+			"delay": delay})
+		s.opts.emit(&Event{Type: EventRetry, Command: result.Name, CommandID: result.ID, Attempt: result.Attempts,
+			Error: cause.Error()})
+		s.opts.Clock.Sleep(delay)
+		if s.Backoff > 1 {
+			delay = time.Duration(float64(delay) * s.Backoff)
+		}
+	}
+}
+
+// recordExpectations records which of the command's own expectations its last attempt matched in result
+func (s *SilentCmd) recordExpectations(result *Result) {
+	unmatched := make(map[*Expectation]bool)
+	for _, e := range s.Expectations {
+		unmatched[e] = true
+	}
+	result.Matched = 0
+	result.ExpectationResults = nil
+	for _, e := range s.declared {
+		result.ExpectationResults = append(result.ExpectationResults, &ExpectationResult{Input: e.Input,
+			Matched: !unmatched[e]})
+		if !unmatched[e] {
+			result.Matched++
+		}
+	}
+}
+
+// FailurePolicy decides what a Runner does with the remaining commands once one of them fails
+type FailurePolicy int
+
+const (
+	// FailFast stops starting new commands as soon as any command fails
+	FailFast FailurePolicy = iota
+	// FinishIndependent keeps running every command that does not depend, directly or not, on a failed command
+	FinishIndependent
+)
+
+// ParseFailurePolicy converts "fail-fast" or "finish-independent" to a FailurePolicy
+func ParseFailurePolicy(policy string) (FailurePolicy, error) {
+	switch policy {
+	case "fail-fast":
+		return FailFast, nil
+	case "finish-independent":
+		return FinishIndependent, nil
+	}
+	return FailFast, fmt.Errorf("unknown failure policy %q, must be fail-fast or finish-independent", policy)
+}
+
+// Runner executes a set of commands, honoring their dependencies and running up to Parallel of them at once
+type Runner struct {
+	Commands SilentCmds
+	// Parallel is the maximum number of commands run at the same time, values below 1 are treated as 1
+	Parallel int
+	Policy   FailurePolicy
+	// Results holds the result of each command, in the same order as Commands, once Run has returned
+	Results []*Result
+	// State, if set, records every command that completes successfully. Failing to save it is logged as a warning
+	State *State
+	// Resume skips commands that State says have already completed with the same definition
+	Resume bool
+	// From skips every command declared before the named command
+	From string
+	// Only skips every command not named here, if it's not empty
+	Only []string
+	// Skip skips every command named here
+	Skip []string
+	// OnFailure, OnSuccess and Always are hooks run once every command has finished. OnFailure is run
+	// with the result of the first command that failed
+	OnFailure SilentCmds
+	OnSuccess SilentCmds
+	Always    SilentCmds
+	// HookResults holds the results of the runner's own hooks once Run has returned
+	HookResults []*Result
+	// Start, Duration and Err describe the last run once Run has returned. Err is the first error encountered,
+	// or nil if every command finished successfully
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// cmdDone is sent by a running command's goroutine once it has finished
+type cmdDone struct {
+	index  int
+	result *Result
+}
+
+// Run executes all of the runner's commands with the given options. Commands whose dependencies are satisfied are
+// started in the order they were declared. When a command fails (and doesn't ignore errors) its dependents are never
+// started, and depending on Policy, neither is anything else. The first error encountered is returned, or io.EOF if
+// every command finished successfully
+func (r *Runner) Run(opts ...RunOption) error {
+	o := NewRunOptions(opts...)
+	r.Start = o.Clock.Now()
+	defer func() {
+		r.Duration = o.Clock.Now().Sub(r.Start)
+	}()
+	dependents, waiting, err := r.graph()
+	if err == nil {
+		err = r.checkSelectors()
+	}
+	if err != nil {
+		r.Err = err
+		return err
+	}
+
+	parallel := r.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	o.Logger.Info("run starting", Fields{"commands": len(r.Commands), "parallel": parallel})
+	o.emit(&Event{Type: EventRunStart, Commands: len(r.Commands)})
+	cmdOptions := make([]*RunOptions, len(r.Commands))
+	for i, c := range r.Commands {
+		cmdOptions[i] = o
+		if parallel > 1 {
+			// prefix output so interleaved commands can be told apart
+			prefixed := *o
+			u := o.Ui
+			if u == nil {
+				// commands not made with NewSilentCmd have no ui until Init, which is only called once they start
+				if c.coloredUI == nil {
+					c.coloredUI = ui.NewColoredUi()
+				}
+				u = c.coloredUI
+			}
+			prefixed.Ui = &ui.TargettedUi{Target: c.DisplayName(), Ui: u}
+			cmdOptions[i] = &prefixed
+		}
+	}
+
+	// commands are looked up in the state as it was before the run, so that one completing never causes a later
+	// command to be skipped
+	var resumed *State
+	if r.Resume && r.State != nil {
+		resumed = r.State.snapshot()
+	}
+	repeats := r.repeats()
+
+	r.Results = make([]*Result, len(r.Commands))
+	var ready []int
+	for i, c := range r.Commands {
+		r.Results[i] = NewResult(c)
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	done := make(chan cmdDone)
+	running := 0
+	stopped := false
+	var firstErr error
+	var firstFailed *Result
+	finish := func(i int, result *Result) {
+		r.Results[i] = result
+		if !result.Succeeded() {
+			if firstErr == nil {
+				firstErr = result.Err
+				firstFailed = result
+			}
+			if r.Policy == FailFast {
+				stopped = true
+			}
+			return
+		}
+		if r.State != nil && (result.Status == StatusOK || result.Status == StatusRetried) {
+			// the state only saves time on the next run, so failing to save it is no reason to stop this one
+			if err := r.State.Complete(r.Commands[i], repeats[i]); err != nil {
+				o.Logger.Warn("could not save state", Fields{"command": result.Name, "error": err})
+			}
+		}
+		for _, dependent := range dependents[i] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	for {
+		for !stopped && running < parallel && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			if reason := r.skipReason(i, resumed, repeats[i]); reason != "" {
+				result := NewResult(r.Commands[i])
+				result.ID = o.nextID()
+				result.Status = StatusSkipped
+				result.SkipReason = reason
+				o.Logger.Info("skipped", Fields{"command": result.Name, "reason": reason})
+				o.emit(&Event{Type: EventCommandEnd, Command: result.Name, CommandID: result.ID, Result: result})
+				finish(i, result)
+				continue
+			}
+			running++
+			go func(i int) {
+				done <- cmdDone{index: i, result: r.Commands[i].run(cmdOptions[i])}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		finish(d.index, d.result)
+	}
+
+	r.HookResults = r.runHooks(firstFailed, firstErr, o)
+	r.Err = firstErr
+	if firstErr != nil {
+		o.Logger.Error("run failed", Fields{"error": firstErr})
+		o.emit(&Event{Type: EventRunEnd, Status: StatusFailed, Error: firstErr.Error()})
+		return firstErr
+	}
+	o.Logger.Info("run finished", nil)
+	o.emit(&Event{Type: EventRunEnd, Status: StatusOK})
+	return io.EOF
+}
+
+// runHooks runs the runner's own hooks once all of its commands have finished. If the run failed, err is why,
+// and failed is the result of the command that failed, if it was a command that failed
+func (r *Runner) runHooks(failed *Result, err error, o *RunOptions) []*Result {
+	env := []string{"SILENT_STATUS=" + string(StatusOK)}
+	var results []*Result
+	if err != nil {
+		env = []string{"SILENT_STATUS=" + string(StatusFailed), "SILENT_ERROR=" + failureCause(err).Error()}
+		if failed != nil {
+			env = failed.Environ()
+		}
+		results = runHooks(HookOnFailure, r.OnFailure, env, o)
+	} else {
+		results = runHooks(HookOnSuccess, r.OnSuccess, env, o)
+	}
+	return append(results, runHooks(HookAlways, r.Always, env, o)...)
+}
+
+// checkSelectors returns an error if From, Only or Skip name a command that doesn't exist
+func (r *Runner) checkSelectors() error {
+	names := make(map[string]bool)
+	for _, c := range r.Commands {
+		names[c.DisplayName()] = true
+	}
+	selected := append(append([]string(nil), r.Only...), r.Skip...)
+	if r.From != "" {
+		selected = append(selected, r.From)
+	}
+	for _, name := range selected {
+		if !names[name] {
+			return fmt.Errorf("no command named %q to select", name)
+		}
+	}
+	return nil
+}
+
+// skipReason returns why the command at index i should not be run because of the runner's selectors
+// or the resumed state, or an empty string if it should be run. n is as for State.Lookup
+func (r *Runner) skipReason(i int, resumed *State, n int) string {
+	c := r.Commands[i]
+	name := c.DisplayName()
+	if r.From != "" && i < r.indexOf(r.From) {
+		return fmt.Sprintf("before %s", r.From)
+	}
+	if len(r.Only) > 0 && !contains(r.Only, name) {
+		return "not selected"
+	}
+	if contains(r.Skip, name) {
+		return "deselected"
+	}
+	if resumed != nil {
+		if entry := resumed.Lookup(c, n); entry != nil {
+			return fmt.Sprintf("already completed at %s", entry.CompletedAt.Format(time.RFC3339))
+		}
+	}
+	return ""
+}
+
+// repeats returns, for each command, the number of commands declared before it with the same definition
+func (r *Runner) repeats() []int {
+	seen := make(map[string]int)
+	repeats := make([]int, len(r.Commands))
+	for i, c := range r.Commands {
+		hash := c.Hash()
+		repeats[i] = seen[hash]
+		seen[hash]++
+	}
+	return repeats
+}
+
+// indexOf returns the index of the first command with the given name, or -1
+func (r *Runner) indexOf(name string) int {
+	for i, c := range r.Commands {
+		if c.DisplayName() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains returns true if list contains s
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// graph validates the dependencies between the runner's commands, returning the indexes of each command's dependents
+// and the number of dependencies each command is waiting on
+func (r *Runner) graph() (dependents [][]int, waiting []int, err error) {
+	names := make(map[string]int)
+	for i, c := range r.Commands {
+		if c.Name == "" {
+			continue
+		}
+		if _, ok := names[c.Name]; ok {
+			return nil, nil, fmt.Errorf("command name %q is used more than once", c.Name)
+		}
+		names[c.Name] = i
+	}
+
+	dependents = make([][]int, len(r.Commands))
+	waiting = make([]int, len(r.Commands))
+	for i, c := range r.Commands {
+		for _, dep := range c.DependsOn {
+			j, ok := names[dep]
+			if !ok {
+				return nil, nil, fmt.Errorf("command %q depends on unknown command %q", c.DisplayName(), dep)
+			}
+			if j == i {
+				return nil, nil, fmt.Errorf("command %q depends on itself", c.DisplayName())
+			}
+			dependents[j] = append(dependents[j], i)
+			waiting[i]++
+		}
+	}
+
+	// walk the graph once up front so cycles are reported before anything runs
+	remaining := append([]int(nil), waiting...)
+	var queue []int
+	for i, n := range remaining {
+		if n == 0 {
+			queue = append(queue, i)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, j := range dependents[i] {
+			remaining[j]--
+			if remaining[j] == 0 {
+				queue = append(queue, j)
+			}
+		}
+	}
+	if visited != len(r.Commands) {
+		var cycle []string
+		for i, n := range remaining {
+			if n > 0 {
+				cycle = append(cycle, r.Commands[i].DisplayName())
+			}
+		}
+		return nil, nil, fmt.Errorf("dependency cycle involving commands: %s", strings.Join(cycle, ", "))
+	}
+	return dependents, waiting, nil
+}
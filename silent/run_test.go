@@ -0,0 +1,155 @@
+package silent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alistanis/silentinstall/silent/ui"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// shellCmd returns a named SilentCmd running script with sh
+func shellCmd(name, script string, dependsOn ...string) *SilentCmd {
+	s := NewSilentCmd()
+	s.Name = name
+	s.CmdString = script
+	s.DependsOn = dependsOn
+	s.Cmd = exec.Command("sh", "-c", script)
+	return s
+}
+
+// recordCmd returns a command that appends its name to the file at path
+func recordCmd(path, name string, dependsOn ...string) *SilentCmd {
+	return shellCmd(name, "echo "+name+" >> "+path, dependsOn...)
+}
+
+func readRecord(path string) []string {
+	data, _ := ioutil.ReadFile(path)
+	return strings.Fields(string(data))
+}
+
+func TestRunner_Run(t *testing.T) {
+	Convey("Given a temporary directory to record command order in", t, func() {
+		dir, err := ioutil.TempDir("", "silent-run")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		record := filepath.Join(dir, "record")
+
+		Convey("Commands run after the commands they depend on", func() {
+			r := &Runner{Commands: SilentCmds{
+				recordCmd(record, "c", "b"),
+				recordCmd(record, "b", "a"),
+				recordCmd(record, "a"),
+			}}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "b", "c"})
+		})
+
+		Convey("Exec runs every command, not just the first", func() {
+			cmds := SilentCmds{recordCmd(record, "a"), recordCmd(record, "b")}
+			So(cmds.Exec(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "b"})
+		})
+
+		Convey("Independent commands run at the same time when parallel", func() {
+			r := &Runner{Parallel: 3, Commands: SilentCmds{
+				shellCmd("one", "sleep 1"),
+				shellCmd("two", "sleep 1"),
+				shellCmd("three", "sleep 1"),
+			}}
+			start := time.Now()
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(time.Since(start), ShouldBeLessThan, 2*time.Second)
+
+			Convey("And their output is prefixed with their name", func() {
+				for _, c := range r.Commands {
+					target, ok := c.ui().(*ui.TargettedUi)
+					So(ok, ShouldBeTrue)
+					So(target.Target, ShouldEqual, c.Name)
+				}
+			})
+		})
+
+		Convey("Commands not made with NewSilentCmd can run in parallel", func() {
+			r := &Runner{Parallel: 2, Commands: SilentCmds{
+				&SilentCmd{Name: "a", Cmd: exec.Command("true")},
+				&SilentCmd{Name: "b", Cmd: exec.Command("true")},
+			}}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(r.Commands[0].ui().(*ui.TargettedUi).Ui, ShouldNotBeNil)
+		})
+
+		Convey("With fail fast, nothing new is started after a failure", func() {
+			r := &Runner{Policy: FailFast, Commands: SilentCmds{
+				shellCmd("broken", "/this/does/not/exist"),
+				recordCmd(record, "after", "broken"),
+				recordCmd(record, "independent"),
+			}}
+			r.Commands[0].Cmd = exec.Command("/this/does/not/exist")
+			err := r.Run()
+			So(err, ShouldNotBeNil)
+			So(err, ShouldNotEqual, io.EOF)
+			So(readRecord(record), ShouldBeEmpty)
+		})
+
+		Convey("With finish independent, only dependents of a failure are not run", func() {
+			r := &Runner{Policy: FinishIndependent, Commands: SilentCmds{
+				shellCmd("broken", "/this/does/not/exist"),
+				recordCmd(record, "after", "broken"),
+				recordCmd(record, "independent"),
+			}}
+			r.Commands[0].Cmd = exec.Command("/this/does/not/exist")
+			err := r.Run()
+			So(err, ShouldNotBeNil)
+			So(err, ShouldNotEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"independent"})
+		})
+	})
+}
+
+func TestRunner_graph(t *testing.T) {
+	Convey("Invalid dependency graphs are rejected before anything runs", t, func() {
+		Convey("Unknown dependencies", func() {
+			r := &Runner{Commands: SilentCmds{shellCmd("a", "true", "missing")}}
+			So(r.Run().Error(), ShouldContainSubstring, `unknown command "missing"`)
+		})
+
+		Convey("Duplicate names", func() {
+			r := &Runner{Commands: SilentCmds{shellCmd("a", "true"), shellCmd("a", "true")}}
+			So(r.Run().Error(), ShouldContainSubstring, "more than once")
+		})
+
+		Convey("Cycles", func() {
+			r := &Runner{Commands: SilentCmds{
+				shellCmd("a", "true", "c"),
+				shellCmd("b", "true", "a"),
+				shellCmd("c", "true", "b"),
+				shellCmd("d", "true"),
+			}}
+			err := r.Run()
+			So(err.Error(), ShouldContainSubstring, "dependency cycle")
+			So(err.Error(), ShouldEndWith, "a, b, c")
+		})
+	})
+}
+
+func TestParseFailurePolicy(t *testing.T) {
+	Convey("We can parse failure policies from strings", t, func() {
+		p, err := ParseFailurePolicy("fail-fast")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, FailFast)
+		p, err = ParseFailurePolicy("finish-independent")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, FinishIndependent)
+		_, err = ParseFailurePolicy("whatever")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,264 @@
+package silent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Session is an expect style conversation with a Transport, for Go programs that want to drive a command step by
+// step rather than describe it up front as a SilentCmd. Expectations are matched the same way in both.
+// A Session is not safe for use by multiple goroutines
+type Session struct {
+	t      Transport
+	chunks chan string
+	errs   chan error
+	done   chan struct{}
+	// buffer holds output that hasn't been consumed by a match yet
+	buffer bytes.Buffer
+	output bytes.Buffer
+	// err is set once the transport's output has ended
+	err    error
+	closed bool
+	// waited is set once Wait has finished, which waitErr is the result of
+	waited  bool
+	waitErr error
+	opts    *RunOptions
+}
+
+// Spawn starts the named program with the given arguments, and returns a Session for talking to it
+func Spawn(name string, args ...string) (*Session, error) {
+	return NewSession(&ExecTransport{Cmd: exec.Command(name, args...)})
+}
+
+// NewSession starts t, and returns a Session for talking to it with the given options
+func NewSession(t Transport, opts ...RunOption) (*Session, error) {
+	if err := t.Start(); err != nil {
+		return nil, err
+	}
+	s := &Session{
+		t:      t,
+		chunks: make(chan string),
+		errs:   make(chan error),
+		done:   make(chan struct{}),
+		opts:   NewRunOptions(opts...),
+	}
+	// unlike SilentCmd.Exec, stderr is just more output to match against
+	go readToChannel(t.Stdout(), s.chunks, s.errs, s.done)
+	if e := t.Stderr(); e != nil {
+		go readToChannel(e, s.chunks, nil, s.done)
+	}
+	return s, nil
+}
+
+// Expect waits up to timeout for output matching the regular expression pattern, returning the match.
+// Output up to the end of the match is consumed. A timeout of zero waits forever.
+// io.EOF is returned if the output ends without matching
+func (s *Session) Expect(pattern string, timeout time.Duration) (*Match, error) {
+	_, m, err := s.ExpectAny(timeout, pattern)
+	return m, err
+}
+
+// ExpectAny waits up to timeout for output matching any of patterns, returning the index of the pattern that
+// matched first in the output, and the match
+func (s *Session) ExpectAny(timeout time.Duration, patterns ...string) (int, *Match, error) {
+	expectations := make([]*Expectation, len(patterns))
+	for i, p := range patterns {
+		expectations[i] = &Expectation{Input: p, Regexp: true}
+		if err := expectations[i].Compile(); err != nil {
+			return -1, nil, err
+		}
+	}
+	return s.ExpectExpectations(timeout, expectations...)
+}
+
+// ExpectExpectations waits up to timeout for output matching any of expectations, returning the index of the
+// expectation that matched first in the output, and the match. Expectations' outputs are not sent
+func (s *Session) ExpectExpectations(timeout time.Duration, expectations ...*Expectation) (int, *Match, error) {
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = s.opts.Clock.After(timeout)
+	}
+	for {
+		index := -1
+		var first *Match
+		for i, e := range expectations {
+			if m := e.Find(s.buffer.String()); m != nil && (first == nil || m.end < first.end) {
+				index, first = i, m
+			}
+		}
+		if first != nil {
+			s.opts.emit(&Event{Type: EventExpectationMatched, Expectation: expectations[index].Input})
+			s.buffer.Next(first.end)
+			return index, first, nil
+		}
+		if s.err != nil {
+			return -1, nil, s.err
+		}
+
+		select {
+		case str := <-s.chunks:
+			s.read(str)
+			s.buffer.WriteString(str)
+		case err := <-s.errs:
+			s.err = err
+		case <-timer:
+			return -1, nil, fmt.Errorf("timed out after %s waiting for %s", timeout, describeExpectations(expectations))
+		}
+	}
+}
+
+// read records str having been read from the session
+func (s *Session) read(str string) {
+	s.opts.Logger.Trace("read", Fields{"bytes": len(str), "text": str})
+	s.opts.transcribe(str)
+	s.opts.emit(&Event{Type: EventOutputChunk, Text: str})
+	s.output.WriteString(str)
+}
+
+// sent records text having been sent to the session, text should already be masked if it's a secret
+func (s *Session) sent(text string) {
+	s.opts.Logger.Debug("sending", Fields{"text": text})
+	s.opts.transcribe(text)
+	s.opts.emit(&Event{Type: EventResponseSent, Text: text})
+}
+
+// describeExpectations lists expectations' inputs for error messages
+func describeExpectations(expectations []*Expectation) string {
+	var b bytes.Buffer
+	for i, e := range expectations {
+		if i > 0 {
+			b.WriteString(" or ")
+		}
+		fmt.Fprintf(&b, "%q", e.Input)
+	}
+	return b.String()
+}
+
+// Send writes text as is
+func (s *Session) Send(text string) error {
+	s.sent(text)
+	_, err := io.WriteString(s.t.Stdin(), text)
+	return err
+}
+
+// SendLine writes l, adding a newline if it doesn't already end with one
+func (s *Session) SendLine(l string) error {
+	s.sent(line(l))
+	_, err := io.WriteString(s.t.Stdin(), line(l))
+	return err
+}
+
+// SendSecret writes secret as a line, like SendLine, but never logs it
+func (s *Session) SendSecret(secret string) error {
+	s.sent(line(secretMask))
+	_, err := io.WriteString(s.t.Stdin(), line(secret))
+	return err
+}
+
+// Interact hands the session over to the user, copying os.Stdin to the session and its output to os.Stdout
+// until the output ends. It's meant to be the last step of a session: a read of os.Stdin that's in progress when
+// the output ends can't be interrupted, and whatever it reads is dropped rather than sent
+func (s *Session) Interact() error {
+	return s.InteractWith(os.Stdin, os.Stdout)
+}
+
+// InteractWith copies in to the session and the session's output to out until the output ends. Any output
+// not yet consumed by Expect is written first. The output ending with io.EOF is not an error
+func (s *Session) InteractWith(in io.Reader, out io.Writer) error {
+	if _, err := out.Write(s.buffer.Bytes()); err != nil {
+		return err
+	}
+	s.buffer.Reset()
+	stop := make(chan struct{})
+	defer close(stop)
+	go copyUntil(s.t.Stdin(), in, stop)
+	for s.err == nil {
+		select {
+		case str := <-s.chunks:
+			s.read(str)
+			if _, err := io.WriteString(out, str); err != nil {
+				return err
+			}
+		case err := <-s.errs:
+			s.err = err
+		}
+	}
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// copyUntil copies in to w until in ends or stop is closed, so in is no longer read once nothing wants it
+func copyUntil(w io.Writer, in io.Reader, stop chan struct{}) {
+	data := make([]byte, 256)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		n, err := in.Read(data)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if n > 0 {
+			if _, werr := w.Write(data[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Signal sends sig to the other end of the session, if its transport supports it
+func (s *Session) Signal(sig os.Signal) error {
+	return s.t.Signal(sig)
+}
+
+// Output returns everything the session has read so far
+func (s *Session) Output() string {
+	return s.output.String()
+}
+
+// Close closes the session's input, telling the other end that nothing more is coming
+func (s *Session) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.t.Stdin().Close()
+}
+
+// Wait closes the session's input, reads the rest of its output and waits for it to finish, returning an error
+// if it finished unsuccessfully, e.g. an *exec.ExitError. Waiting again returns the same result
+func (s *Session) Wait() error {
+	if s.waited {
+		return s.waitErr
+	}
+	s.Close()
+	for s.err == nil {
+		select {
+		case str := <-s.chunks:
+			s.read(str)
+			s.buffer.WriteString(str)
+		case err := <-s.errs:
+			s.err = err
+		}
+	}
+	close(s.done)
+	err := s.t.Wait()
+	if err == nil && s.err != io.EOF {
+		err = s.err
+	}
+	s.waited, s.waitErr = true, err
+	return err
+}
@@ -0,0 +1,146 @@
+package silent
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSession(t *testing.T) {
+	Convey("A session can hold a conversation one step at a time", t, func() {
+		s, err := Spawn("sh", "-c", `printf 'Name? '; read name; echo "Hello $name, you are user 42"; exit 3`)
+		So(err, ShouldBeNil)
+
+		m, err := s.Expect(`Name\? `, time.Second)
+		So(err, ShouldBeNil)
+		So(m.Text, ShouldEqual, "Name? ")
+		So(s.SendLine("Chris"), ShouldBeNil)
+
+		m, err = s.Expect(`Hello (\w+), you are user (\d+)`, time.Second)
+		So(err, ShouldBeNil)
+		So(m.Captures, ShouldResemble, []string{"Chris", "42"})
+
+		err = s.Wait()
+		So(err, ShouldHaveSameTypeAs, &exec.ExitError{})
+		So(s.Output(), ShouldEqual, "Name? Hello Chris, you are user 42\n")
+	})
+
+	Convey("ExpectAny reports whichever pattern shows up first", t, func() {
+		s, err := Spawn("sh", "-c", "echo 'warning: disk almost full'; echo 'error: disk full'")
+		So(err, ShouldBeNil)
+		i, m, err := s.ExpectAny(time.Second, "error: (.*)", "warning: (.*)")
+		So(err, ShouldBeNil)
+		So(i, ShouldEqual, 1)
+		So(m.Captures, ShouldResemble, []string{"disk almost full"})
+
+		i, m, err = s.ExpectAny(time.Second, "error: (.*)", "warning: (.*)")
+		So(err, ShouldBeNil)
+		So(i, ShouldEqual, 0)
+		So(m.Before, ShouldEqual, "\n")
+		So(s.Wait(), ShouldBeNil)
+	})
+
+	Convey("Expect gives up after its timeout", t, func() {
+		s, err := Spawn("sleep", "5")
+		So(err, ShouldBeNil)
+		_, err = s.Expect("never", 50*time.Millisecond)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "timed out")
+		So(s.Signal(os.Kill), ShouldBeNil)
+		So(s.Wait(), ShouldNotBeNil)
+	})
+
+	Convey("Expect returns io.EOF if the output ends without matching", t, func() {
+		s, err := Spawn("echo", "bye")
+		So(err, ShouldBeNil)
+		_, err = s.Expect("hello", time.Second)
+		So(err, ShouldEqual, io.EOF)
+		So(s.Output(), ShouldEqual, "bye\n")
+		So(s.Wait(), ShouldBeNil)
+	})
+
+	Convey("Invalid patterns are reported", t, func() {
+		s, err := Spawn("true")
+		So(err, ShouldBeNil)
+		_, err = s.Expect("(", time.Second)
+		So(err, ShouldNotBeNil)
+		So(s.Wait(), ShouldBeNil)
+	})
+
+	Convey("Secrets are sent like any other line", t, func() {
+		s, err := Spawn("sh", "-c", "read p; echo got $p")
+		So(err, ShouldBeNil)
+		So(s.SendSecret("hunter2"), ShouldBeNil)
+		_, err = s.Expect("got hunter2", time.Second)
+		So(err, ShouldBeNil)
+		So(s.Wait(), ShouldBeNil)
+	})
+
+	Convey("A session can be handed over to interact with", t, func() {
+		s, err := Spawn("sh", "-c", "printf 'ready> '; read a; echo one $a; read b; echo two $b")
+		So(err, ShouldBeNil)
+		_, err = s.Expect("ready", time.Second)
+		So(err, ShouldBeNil)
+
+		out := &bytes.Buffer{}
+		So(s.InteractWith(strings.NewReader("x\ny\n"), out), ShouldBeNil)
+		So(out.String(), ShouldEqual, "> one x\ntwo y\n")
+		So(s.Wait(), ShouldBeNil)
+	})
+
+	Convey("Input is no longer read once an interaction is over", t, func() {
+		s, err := Spawn("sh", "-c", "read a; echo got $a")
+		So(err, ShouldBeNil)
+		lines := make(chan string)
+		go func() { lines <- "x\n" }()
+		So(s.InteractWith(&chanReader{lines}, ioutil.Discard), ShouldBeNil)
+		// a read that was already waiting may still get one more line, but gives up without reading again
+		for i, line := range []string{"y\n", "z\n"} {
+			select {
+			case lines <- line:
+				So(i, ShouldEqual, 0)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		So(s.Wait(), ShouldBeNil)
+	})
+
+	Convey("Waiting again returns the same result", t, func() {
+		s, err := Spawn("sh", "-c", "exit 3")
+		So(err, ShouldBeNil)
+		err = s.Wait()
+		So(err, ShouldHaveSameTypeAs, &exec.ExitError{})
+		So(s.Wait(), ShouldEqual, err)
+	})
+
+	Convey("Sessions work over any transport", t, func() {
+		client, server := net.Pipe()
+		go fakeDevice(server)
+		s, err := NewSession(&StreamTransport{Conn: client})
+		So(err, ShouldBeNil)
+		_, err = s.Expect("login: ", time.Second)
+		So(err, ShouldBeNil)
+		So(s.Send("admin\n"), ShouldBeNil)
+		m, err := s.Expect(`Welcome (\w+)`, time.Second)
+		So(err, ShouldBeNil)
+		So(m.Captures[0], ShouldEqual, "admin")
+		So(s.Wait(), ShouldBeNil)
+	})
+}
+
+// chanReader reads whatever is sent on its channel, one send per read
+type chanReader struct {
+	c chan string
+}
+
+func (r *chanReader) Read(p []byte) (int, error) {
+	return copy(p, <-r.c), nil
+}
@@ -0,0 +1,145 @@
+package silent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State records which commands have completed successfully, so that a failed run can be resumed
+// without repeating them. It is saved to its file every time a command completes
+type State struct {
+	// Completed maps the hash of each completed command's definition, numbered if the same definition appears
+	// more than once in a run, to when it completed
+	Completed map[string]*StateEntry `json:"completed"`
+	path      string
+	l         sync.Mutex
+}
+
+// StateEntry describes a completed command
+type StateEntry struct {
+	Name        string    `json:"name"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// LoadState loads the state saved at path, returning an empty State if there is no file there yet
+func LoadState(path string) (*State, error) {
+	st := &State{Completed: make(map[string]*StateEntry), path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	if st.Completed == nil {
+		st.Completed = make(map[string]*StateEntry)
+	}
+	return st, nil
+}
+
+// Lookup returns the entry for s if it has completed with the same definition it has now, or nil. n is the number
+// of commands before s in its run with the same definition, so that a step repeated in a run is told apart
+func (st *State) Lookup(s *SilentCmd, n int) *StateEntry {
+	st.l.Lock()
+	defer st.l.Unlock()
+	return st.Completed[stateKey(s, n)]
+}
+
+// Complete records s as completed and saves the state. n is as for Lookup
+func (st *State) Complete(s *SilentCmd, n int) error {
+	st.l.Lock()
+	defer st.l.Unlock()
+	st.Completed[stateKey(s, n)] = &StateEntry{Name: s.DisplayName(), CompletedAt: time.Now().UTC()}
+	return st.save()
+}
+
+// snapshot returns a copy of the state as it is now, which commands completing don't change
+func (st *State) snapshot() *State {
+	st.l.Lock()
+	defer st.l.Unlock()
+	snap := &State{Completed: make(map[string]*StateEntry, len(st.Completed))}
+	for key, entry := range st.Completed {
+		snap.Completed[key] = entry
+	}
+	return snap
+}
+
+// stateKey returns the key s is completed under, where n is the number of commands before it in its run with the
+// same definition. The first is just the hash
+func stateKey(s *SilentCmd, n int) string {
+	if n == 0 {
+		return s.Hash()
+	}
+	return fmt.Sprintf("%s#%d", s.Hash(), n+1)
+}
+
+// save writes the state to a temporary file and moves it into place, so an interrupted run never leaves
+// a truncated state file behind
+func (st *State) save() error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(st.path), filepath.Base(st.path))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), st.path)
+}
+
+// Hash returns a hash of this command's resolved definition, which changes whenever anything about what the
+// command does changes. The values of secret variables and secret responses aren't part of it, so that they
+// can't be guessed from a state file
+func (s *SilentCmd) Hash() string {
+	// SilentCmd's runtime fields are all excluded from JSON, and its definition can always be marshaled
+	data, _ := json.Marshal(s.definition())
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// definition returns a copy of this command as it was declared, and its hooks, with secrets masked
+func (s *SilentCmd) definition() *SilentCmd {
+	def := *s
+	expectations := s.Expectations
+	if s.declared != nil {
+		expectations = s.declared
+	}
+	def.Expectations = nil
+	for _, e := range expectations {
+		masked := *e
+		masked.Output = e.MaskedOutput()
+		def.Expectations = append(def.Expectations, &masked)
+	}
+	for _, field := range def.templated() {
+		*field = s.shown(*field)
+	}
+	def.OnFailure, def.OnSuccess, def.Always = definitions(s.OnFailure), definitions(s.OnSuccess), definitions(s.Always)
+	return &def
+}
+
+// definitions returns the definitions of cmds, see SilentCmd.definition
+func definitions(cmds SilentCmds) SilentCmds {
+	var defs SilentCmds
+	for _, c := range cmds {
+		defs = append(defs, c.definition())
+	}
+	return defs
+}
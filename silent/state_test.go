@@ -0,0 +1,176 @@
+package silent
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestState(t *testing.T) {
+	Convey("Given a temporary directory for state and records", t, func() {
+		dir, err := ioutil.TempDir("", "silent-state")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		statePath := filepath.Join(dir, "state")
+		record := filepath.Join(dir, "record")
+
+		Convey("A missing state file is an empty state", func() {
+			st, err := LoadState(statePath)
+			So(err, ShouldBeNil)
+			So(st.Completed, ShouldBeEmpty)
+		})
+
+		Convey("A corrupt state file is an error", func() {
+			So(ioutil.WriteFile(statePath, []byte("{"), 0644), ShouldBeNil)
+			_, err := LoadState(statePath)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Completed commands are saved and can be looked up again", func() {
+			st, err := LoadState(statePath)
+			So(err, ShouldBeNil)
+			c := recordCmd(record, "a")
+			So(st.Complete(c, 0), ShouldBeNil)
+
+			st, err = LoadState(statePath)
+			So(err, ShouldBeNil)
+			entry := st.Lookup(c, 0)
+			So(entry, ShouldNotBeNil)
+			So(entry.Name, ShouldEqual, "a")
+
+			Convey("But not once their definition changes", func() {
+				c.Retries = 3
+				So(st.Lookup(c, 0), ShouldBeNil)
+			})
+
+			Convey("Or when it's a later repeat of the same definition", func() {
+				So(st.Lookup(c, 1), ShouldBeNil)
+			})
+		})
+
+		Convey("A command's hash doesn't change when its expectations are used up", func() {
+			c := shellCmd("a", "printf 'Name?\\n'; read name")
+			c.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+			hash := c.Hash()
+			So(c.Run().Status, ShouldEqual, StatusOK)
+			So(c.Expectations, ShouldBeEmpty)
+			So(c.Hash(), ShouldEqual, hash)
+		})
+
+		Convey("A command's hash doesn't depend on the values of secrets", func() {
+			hash := func(password, user string) string {
+				cfg, err := NewConfigFromJSON([]byte(`{
+					"vars": {"PW": {"secret": true, "default": "` + password + `"}, "USER": {"default": "` + user + `"}},
+					"commands": [{"cmd": "login {{.USER}} {{.PW}}", "expectations": [{"input": "?", "output": "{{.PW}}"}],
+						"always": [{"cmd": "logout {{.PW}}"}]}]
+				}`))
+				So(err, ShouldBeNil)
+				return cfg.Commands[0].Hash()
+			}
+			So(hash("hunter2", "admin"), ShouldEqual, hash("correct horse", "admin"))
+			So(hash("hunter2", "admin"), ShouldNotEqual, hash("hunter2", "root"))
+		})
+
+		Convey("A resumed run skips commands that already completed", func() {
+			st, err := LoadState(statePath)
+			So(err, ShouldBeNil)
+			broken := shellCmd("c", "/this/does/not/exist")
+			cmds := SilentCmds{recordCmd(record, "a"), recordCmd(record, "b", "a"), broken}
+			r := &Runner{Commands: cmds, State: st}
+			So(r.Run(), ShouldNotEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "b"})
+
+			st, err = LoadState(statePath)
+			So(err, ShouldBeNil)
+			broken.CmdString = "true"
+			broken.Cmd = commandFromString("true")
+			cmds = SilentCmds{recordCmd(record, "a"), recordCmd(record, "b", "a"), broken}
+			r = &Runner{Commands: cmds, State: st, Resume: true}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "b"})
+			So(r.Results[0].Status, ShouldEqual, StatusSkipped)
+			So(r.Results[0].SkipReason, ShouldContainSubstring, "already completed")
+			So(r.Results[1].Status, ShouldEqual, StatusSkipped)
+			So(r.Results[2].Status, ShouldEqual, StatusOK)
+		})
+
+		Convey("Repeated steps are each run once", func() {
+			cmds := func(n int) SilentCmds {
+				cmds := SilentCmds{shellCmd("", "true")}
+				for i := 0; i < n; i++ {
+					cmds = append(cmds, shellCmd("", "echo x >> "+record))
+				}
+				return cmds
+			}
+			st, err := LoadState(statePath)
+			So(err, ShouldBeNil)
+			r := &Runner{Commands: cmds(2), State: st, Resume: true}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"x", "x"})
+
+			st, err = LoadState(statePath)
+			So(err, ShouldBeNil)
+			r = &Runner{Commands: cmds(3), State: st, Resume: true}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"x", "x", "x"})
+			So(r.Results[2].Status, ShouldEqual, StatusSkipped)
+			So(r.Results[3].Status, ShouldEqual, StatusOK)
+		})
+
+		Convey("A state that can't be saved doesn't stop the run", func() {
+			st, err := LoadState(filepath.Join(dir, "missing", "state"))
+			So(err, ShouldBeNil)
+			log := &bytes.Buffer{}
+			r := &Runner{Commands: SilentCmds{recordCmd(record, "a"), recordCmd(record, "b", "a")}, State: st}
+			So(r.Run(WithLogger(NewLogger(log, LevelWarn, LogText))), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "b"})
+			So(log.String(), ShouldContainSubstring, `msg="could not save state" command=a`)
+		})
+	})
+}
+
+func TestRunner_selectors(t *testing.T) {
+	Convey("Given a temporary directory to record command order in", t, func() {
+		dir, err := ioutil.TempDir("", "silent-select")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		record := filepath.Join(dir, "record")
+		cmds := func() SilentCmds {
+			return SilentCmds{recordCmd(record, "a"), recordCmd(record, "b"), recordCmd(record, "c", "a")}
+		}
+
+		Convey("From skips everything declared before the named command", func() {
+			r := &Runner{Commands: cmds(), From: "b"}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"b", "c"})
+		})
+
+		Convey("Only runs only the named commands", func() {
+			r := &Runner{Commands: cmds(), Only: []string{"a", "c"}}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"a", "c"})
+			So(r.Results[1].Status, ShouldEqual, StatusSkipped)
+		})
+
+		Convey("Skip skips the named commands", func() {
+			r := &Runner{Commands: cmds(), Skip: []string{"a"}}
+			So(r.Run(), ShouldEqual, io.EOF)
+			So(readRecord(record), ShouldResemble, []string{"b", "c"})
+		})
+
+		Convey("Selecting a command that doesn't exist is an error", func() {
+			r := &Runner{Commands: cmds(), Only: []string{"d"}}
+			So(r.Run(), ShouldNotEqual, io.EOF)
+			So(readRecord(record), ShouldBeEmpty)
+		})
+	})
+}
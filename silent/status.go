@@ -0,0 +1,227 @@
+package silent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ansiEscape matches the escape sequences terminals use for colors and moving the cursor
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// spinner is drawn a frame at a time at the start of each status line
+var spinner = []string{"|", "/", "-", "\\"}
+
+// StatusDisplay is an EventSink showing a live status line for each running command on a terminal: a spinner, how
+// long it has been running, the expectation it's waiting for and its last line of output. Finished commands are
+// replaced by a one line summary. Commands' output isn't shown otherwise unless Verbose is set, so a run should
+// be given a quiet ui along with the display
+type StatusDisplay struct {
+	// Verbose shows every line of output above the status lines as well
+	Verbose bool
+	// Width is the width of the terminal, status lines are cut to fit it. Values below 1 are treated as 80
+	Width   int
+	w       io.Writer
+	clock   Clock
+	l       sync.Mutex
+	running []*commandStatus
+	// drawn is the number of status lines currently on the terminal
+	drawn   int
+	frame   int
+	stop    chan struct{}
+	stopped chan struct{}
+	// written holds anything written to the display since the last newline
+	written string
+}
+
+// commandStatus is what the display knows about a running command
+type commandStatus struct {
+	// id is the command's CommandID, as names needn't be unique
+	id       int
+	name     string
+	start    time.Time
+	attempt  int
+	awaiting string
+	// last is the last line of output with anything in it, partial holds output since the last newline
+	last    string
+	partial string
+}
+
+// NewStatusDisplay returns a StatusDisplay drawing to w, which should be a terminal. If clock is nil the real
+// clock is used
+func NewStatusDisplay(w io.Writer, clock Clock) *StatusDisplay {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &StatusDisplay{w: w, clock: clock}
+}
+
+// Start redraws the display every interval, moving the spinners and elapsed times along, until Stop is called
+func (d *StatusDisplay) Start(interval time.Duration) {
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+	go func() {
+		defer close(d.stopped)
+		for {
+			select {
+			case <-d.clock.After(interval):
+				d.l.Lock()
+				d.frame++
+				d.draw()
+				d.l.Unlock()
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing the display and clears any status lines left on the terminal
+func (d *StatusDisplay) Stop() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.stopped
+		d.stop = nil
+	}
+	d.l.Lock()
+	defer d.l.Unlock()
+	d.running = nil
+	d.draw()
+}
+
+// Event implements EventSink
+func (d *StatusDisplay) Event(e *Event) {
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	var above []string
+	switch e.Type {
+	case EventCommandStart:
+		d.running = append(d.running, &commandStatus{id: e.CommandID, name: e.Command, start: e.Time})
+	case EventAttemptStart:
+		if c := d.find(e.CommandID); c != nil {
+			c.attempt, c.awaiting, c.partial = e.Attempt, e.Awaiting, ""
+		}
+	case EventExpectationMatched:
+		if c := d.find(e.CommandID); c != nil {
+			c.awaiting = e.Awaiting
+		}
+	case EventOutputChunk:
+		if c := d.find(e.CommandID); c != nil {
+			lines := strings.Split(c.partial+e.Text, "\n")
+			c.partial = lines[len(lines)-1]
+			for _, l := range lines {
+				if l = printable(l); strings.TrimSpace(l) != "" {
+					c.last = l
+				}
+			}
+			if d.Verbose {
+				for _, l := range lines[:len(lines)-1] {
+					above = append(above, fmt.Sprintf("%s: %s", c.name, printable(l)))
+				}
+			}
+		}
+	case EventCommandEnd:
+		for i, c := range d.running {
+			if c.id == e.CommandID {
+				d.running = append(d.running[:i], d.running[i+1:]...)
+				break
+			}
+		}
+		if e.Result != nil {
+			above = append(above, e.Result.String())
+		}
+	default:
+		return
+	}
+	d.draw(above...)
+}
+
+// Write writes each complete line of p above the status lines, so that other output sharing the terminal, such as
+// log entries, doesn't get in the display's way
+func (d *StatusDisplay) Write(p []byte) (int, error) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	lines := strings.Split(d.written+string(p), "\n")
+	d.written = lines[len(lines)-1]
+	if len(lines) > 1 {
+		d.draw(lines[:len(lines)-1]...)
+	}
+	return len(p), nil
+}
+
+// find returns the status of the running command with the given id, or nil if it isn't running
+func (d *StatusDisplay) find(id int) *commandStatus {
+	for _, c := range d.running {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// draw replaces the status lines on the terminal with the current ones, after writing the lines given above them.
+// d.l must be held
+func (d *StatusDisplay) draw(above ...string) {
+	var b bytes.Buffer
+	if d.drawn > 0 {
+		// move up to the first status line and clear everything from there down
+		fmt.Fprintf(&b, "\r\033[%dA\033[J", d.drawn)
+	}
+	for _, l := range above {
+		b.WriteString(l)
+		b.WriteByte('\n')
+	}
+	now := d.clock.Now()
+	for _, c := range d.running {
+		b.WriteString(d.statusLine(c, now))
+		b.WriteByte('\n')
+	}
+	d.drawn = len(d.running)
+	if b.Len() > 0 {
+		d.w.Write(b.Bytes())
+	}
+}
+
+// statusLine describes c as of now, cut to fit the width of the terminal
+func (d *StatusDisplay) statusLine(c *commandStatus, now time.Time) string {
+	elapsed := now.Sub(c.start) / time.Second * time.Second
+	l := fmt.Sprintf("%s %s %s", spinner[d.frame%len(spinner)], c.name, elapsed)
+	if c.attempt > 1 {
+		l += fmt.Sprintf(" (attempt %d)", c.attempt)
+	}
+	if c.awaiting != "" {
+		l += fmt.Sprintf(" waiting for %q", c.awaiting)
+	}
+	if c.last != "" {
+		l += " | " + strings.TrimSpace(c.last)
+	}
+
+	width := d.Width
+	if width < 1 {
+		width = 80
+	}
+	// leave the last column free, so the terminal doesn't wrap
+	if runes := []rune(l); len(runes) > width-1 {
+		l = string(runes[:width-1])
+	}
+	return l
+}
+
+// printable replaces tabs in l with spaces and drops anything else that would move the cursor or change colors
+func printable(l string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, ansiEscape.ReplaceAllString(l, ""))
+}
@@ -0,0 +1,92 @@
+package silent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatusDisplay(t *testing.T) {
+	Convey("A status line is drawn for each running command", t, func() {
+		clock := &fakeClock{now: time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC)}
+		out := &bytes.Buffer{}
+		d := NewStatusDisplay(out, clock)
+		d.Event(&Event{Type: EventCommandStart, Command: "install", CommandID: 1, Time: clock.Now()})
+		d.Event(&Event{Type: EventAttemptStart, Command: "install", CommandID: 1, Attempt: 2, Awaiting: "Password:"})
+		d.Event(&Event{Type: EventOutputChunk, Command: "install", CommandID: 1, Text: "unpacking\n\x1b[1mPassword\x1b[0m for\tbob"})
+		clock.Sleep(3500 * time.Millisecond)
+		out.Reset()
+		d.Event(&Event{Type: EventCommandStart, Command: "other", CommandID: 2, Time: clock.Now()})
+		So(out.String(), ShouldEqual, "\r\x1b[1A\x1b[J"+
+			`| install 3s (attempt 2) waiting for "Password:" | Password for bob`+"\n"+
+			"| other 0s\n")
+
+		Convey("Lines are cut to fit the terminal", func() {
+			d.Width = 20
+			out.Reset()
+			d.Event(&Event{Type: EventExpectationMatched, Command: "install", CommandID: 1, Awaiting: ""})
+			So(out.String(), ShouldEqual, "\r\x1b[2A\x1b[J| install 3s (attem\n| other 0s\n")
+		})
+
+		Convey("Finished commands are summarized above the status lines", func() {
+			out.Reset()
+			d.Event(&Event{Type: EventCommandEnd, Command: "install", CommandID: 1,
+				Result: &Result{Name: "install", Status: StatusOK, Duration: time.Second}})
+			So(out.String(), ShouldEqual, "\r\x1b[2A\x1b[Jinstall: ok in 1s\n| other 0s\n")
+
+			out.Reset()
+			d.Stop()
+			So(out.String(), ShouldEqual, "\r\x1b[1A\x1b[J")
+		})
+
+		Convey("Lines written to the display are shown above the status lines", func() {
+			out.Reset()
+			d.Write([]byte("level=warn msg=one\nlevel=warn"))
+			So(out.String(), ShouldStartWith, "\r\x1b[2A\x1b[Jlevel=warn msg=one\n| install")
+			out.Reset()
+			d.Write([]byte(" msg=two\n"))
+			So(out.String(), ShouldStartWith, "\r\x1b[2A\x1b[Jlevel=warn msg=two\n| install")
+		})
+
+		Convey("Output is only shown when verbose", func() {
+			out.Reset()
+			d.Event(&Event{Type: EventOutputChunk, Command: "other", CommandID: 2, Text: "hello\n"})
+			So(out.String(), ShouldNotContainSubstring, "other: hello")
+
+			d.Verbose = true
+			out.Reset()
+			d.Event(&Event{Type: EventOutputChunk, Command: "install", CommandID: 1, Text: ": \nok\n"})
+			So(out.String(), ShouldStartWith, "\r\x1b[2A\x1b[Jinstall: Password for bob: \ninstall: ok\n")
+		})
+	})
+
+	Convey("Commands with the same name are told apart", t, func() {
+		out := &bytes.Buffer{}
+		d := NewStatusDisplay(out, &fakeClock{})
+		d.Event(&Event{Type: EventCommandStart, Command: "echo hi", CommandID: 1})
+		d.Event(&Event{Type: EventOutputChunk, Command: "echo hi", CommandID: 1, Text: "from the command\n"})
+		// e.g. an always hook running the same thing as the command it's hooked on to
+		d.Event(&Event{Type: EventCommandStart, Command: "echo hi", CommandID: 2})
+		d.Event(&Event{Type: EventOutputChunk, Command: "echo hi", CommandID: 2, Text: "from the hook\n"})
+		d.Event(&Event{Type: EventCommandEnd, Command: "echo hi", CommandID: 2})
+		So(len(d.running), ShouldEqual, 1)
+		So(d.running[0].id, ShouldEqual, 1)
+		So(d.running[0].last, ShouldEqual, "from the command")
+	})
+
+	Convey("The display follows a real run", t, func() {
+		out := &bytes.Buffer{}
+		d := NewStatusDisplay(out, nil)
+		d.Start(time.Millisecond)
+		s := shellCmd("login", "printf 'Password: '; read p; echo welcome")
+		s.Expectations = []*Expectation{{Input: "Password:", Output: "hunter2"}}
+		So(s.Run(WithEventSink(d)).Status, ShouldEqual, StatusOK)
+		d.Stop()
+		So(out.String(), ShouldContainSubstring, `login 0s waiting for "Password:"`)
+		So(out.String(), ShouldContainSubstring, "login: ok in ")
+		So(strings.HasSuffix(out.String(), "\n"), ShouldBeTrue)
+	})
+}
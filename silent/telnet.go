@@ -0,0 +1,174 @@
+package silent
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+)
+
+// telnet protocol bytes, see RFC 854
+const (
+	telnetSE   = 240
+	telnetIP   = 244
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	// options we agree to, RFC 857 and RFC 858
+	telnetOptEcho = 1
+	telnetOptSGA  = 3
+)
+
+// telnetConn reads and writes the data of a telnet session, answering option negotiation as it goes.
+// We let the server echo and suppress go-aheads, which is what interactive consoles expect, and refuse
+// every other option
+type telnetConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// wl serializes writes, as negotiation replies are written while reading
+	wl sync.Mutex
+	// remote and local are the options enabled on the server's side and on ours
+	remote map[byte]bool
+	local  map[byte]bool
+}
+
+func newTelnetConn(conn net.Conn) *telnetConn {
+	return &telnetConn{
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		remote: make(map[byte]bool),
+		local:  make(map[byte]bool),
+	}
+}
+
+// Read reads the session's data into p, with telnet commands removed
+func (t *telnetConn) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		// only block if we have nothing to return yet
+		if n > 0 && t.r.Buffered() == 0 {
+			break
+		}
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b != telnetIAC {
+			p[n] = b
+			n++
+			continue
+		}
+		literal, err := t.command()
+		if err != nil {
+			return n, err
+		}
+		if literal {
+			p[n] = telnetIAC
+			n++
+		}
+	}
+	return n, nil
+}
+
+// command handles the telnet command following an IAC, returning true if it was an escaped IAC data byte
+func (t *telnetConn) command() (literal bool, err error) {
+	cmd, err := t.r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch cmd {
+	case telnetIAC:
+		return true, nil
+	case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+		opt, err := t.r.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		return false, t.negotiate(cmd, opt)
+	case telnetSB:
+		// we never agree to any options with subnegotiation, so skip to the end of it
+		for {
+			b, err := t.r.ReadByte()
+			if err != nil {
+				return false, err
+			}
+			if b != telnetIAC {
+				continue
+			}
+			if b, err = t.r.ReadByte(); err != nil || b == telnetSE {
+				return false, err
+			}
+		}
+	}
+	// anything else (NOP, GA, etc) doesn't concern us
+	return false, nil
+}
+
+// negotiate answers a request to enable or disable an option. Requests that don't change anything
+// aren't answered, so that we never end up in a negotiation loop
+func (t *telnetConn) negotiate(cmd, opt byte) error {
+	switch cmd {
+	case telnetWILL:
+		if opt != telnetOptEcho && opt != telnetOptSGA {
+			return t.send(telnetDONT, opt)
+		}
+		if !t.remote[opt] {
+			t.remote[opt] = true
+			return t.send(telnetDO, opt)
+		}
+	case telnetWONT:
+		if t.remote[opt] {
+			t.remote[opt] = false
+			return t.send(telnetDONT, opt)
+		}
+	case telnetDO:
+		if opt != telnetOptSGA {
+			return t.send(telnetWONT, opt)
+		}
+		if !t.local[opt] {
+			t.local[opt] = true
+			return t.send(telnetWILL, opt)
+		}
+	case telnetDONT:
+		if t.local[opt] {
+			t.local[opt] = false
+			return t.send(telnetWONT, opt)
+		}
+	}
+	return nil
+}
+
+// send writes a telnet command
+func (t *telnetConn) send(cmd ...byte) error {
+	t.wl.Lock()
+	defer t.wl.Unlock()
+	_, err := t.conn.Write(append([]byte{telnetIAC}, cmd...))
+	return err
+}
+
+// interrupt sends telnet's interrupt process command
+func (t *telnetConn) interrupt() error {
+	return t.send(telnetIP)
+}
+
+// Write writes p as session data, escaping IAC bytes and sending newlines as CR LF
+func (t *telnetConn) Write(p []byte) (int, error) {
+	data := bytes.Replace(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC}, -1)
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	data = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+	t.wl.Lock()
+	defer t.wl.Unlock()
+	if _, err := t.conn.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CloseWrite closes the write side of the connection if it supports it
+func (t *telnetConn) CloseWrite() error {
+	return streamWriter{t.conn}.Close()
+}
@@ -0,0 +1,74 @@
+package silent
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// telnetPipe returns a telnetConn and the server's end of its connection
+func telnetPipe() (*telnetConn, net.Conn) {
+	client, server := net.Pipe()
+	return newTelnetConn(client), server
+}
+
+// readReply reads n bytes the telnetConn wrote to server
+func readReply(server net.Conn, n int) []byte {
+	reply := make([]byte, n)
+	io.ReadFull(server, reply)
+	return reply
+}
+
+func TestTelnetConn(t *testing.T) {
+	Convey("Given a telnet connection", t, func() {
+		tc, server := telnetPipe()
+		Reset(func() {
+			server.Close()
+		})
+		read := func() []byte {
+			p := make([]byte, 64)
+			n, _ := tc.Read(p)
+			return p[:n]
+		}
+
+		Convey("Escaped IAC bytes are read as data", func() {
+			go server.Write([]byte("a\xff\xffb"))
+			So(read(), ShouldResemble, []byte("a\xffb"))
+		})
+
+		Convey("Options other than echo and suppress go ahead are refused", func() {
+			go server.Write([]byte{telnetIAC, telnetWILL, 31, telnetIAC, telnetDO, telnetOptEcho, 'x'})
+			result := make(chan []byte)
+			go func() {
+				result <- read()
+			}()
+			So(readReply(server, 6), ShouldResemble, []byte{telnetIAC, telnetDONT, 31, telnetIAC, telnetWONT, telnetOptEcho})
+			So(<-result, ShouldResemble, []byte("x"))
+		})
+
+		Convey("Requests that change nothing aren't answered", func() {
+			go server.Write([]byte{telnetIAC, telnetDO, telnetOptSGA, telnetIAC, telnetDO, telnetOptSGA,
+				telnetIAC, telnetDONT, telnetOptSGA, 'x'})
+			result := make(chan []byte)
+			go func() {
+				result <- read()
+			}()
+			So(readReply(server, 6), ShouldResemble, []byte{telnetIAC, telnetWILL, telnetOptSGA, telnetIAC, telnetWONT, telnetOptSGA})
+			So(<-result, ShouldResemble, []byte("x"))
+		})
+
+		Convey("Writes escape IAC and send newlines as CR LF", func() {
+			go tc.Write([]byte("a\xffb\nc\r\n"))
+			So(readReply(server, 9), ShouldResemble, []byte("a\xff\xffb\r\nc\r\n"))
+		})
+
+		Convey("Interrupting sends interrupt process", func() {
+			go (&NetTransport{telnet: tc}).Signal(os.Interrupt)
+			So(bytes.Equal(readReply(server, 2), []byte{telnetIAC, telnetIP}), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,9 @@
+#!/usr/bin/env bash
+
+printf "Press RETURN to continue\n"
+read
+printf "Hello! Please enter your name!\n"
+read name
+printf "Press RETURN to continue\n"
+read
+printf $name
@@ -0,0 +1,5 @@
+#!/usr/bin/env bash
+
+printf "Password for bob: "
+read password
+printf "got $password\n"
@@ -0,0 +1,153 @@
+package silent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// traceFile is a trace in the Chrome trace event format, as read by chrome://tracing and Perfetto
+type traceFile struct {
+	TraceEvents     []*traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string        `json:"displayTimeUnit"`
+}
+
+// traceEvent is a single event of a trace. Times are in microseconds
+type traceEvent struct {
+	Name string   `json:"name"`
+	Cat  string   `json:"cat,omitempty"`
+	Ph   string   `json:"ph"`
+	Ts   float64  `json:"ts"`
+	Dur  *float64 `json:"dur,omitempty"`
+	Pid  int      `json:"pid"`
+	Tid  int      `json:"tid"`
+	// S is the scope of an instant event
+	S    string                 `json:"s,omitempty"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// traceCommand is what WriteTrace knows about a command while it goes through the events
+type traceCommand struct {
+	tid   int
+	start time.Time
+	// attempt and retry are the command's open attempt and retry delay spans, ended by what comes next
+	attempt, retry *traceEvent
+	// waiting is when the command started waiting for its next match
+	waiting time.Time
+}
+
+// WriteTrace writes events, recorded during a run with an EventRecorder, to w in the Chrome trace event format.
+// The run and each command get a row of spans: the command itself, each of its attempts and the delays before its
+// retries, and each wait for an expectation to be matched, with the responses sent as instant events. Times are
+// relative to the first event, so traces of different runs line up
+func WriteTrace(w io.Writer, events []*Event) error {
+	trace := &traceFile{TraceEvents: []*traceEvent{}, DisplayTimeUnit: "ms"}
+	if len(events) == 0 {
+		return json.NewEncoder(w).Encode(trace)
+	}
+	origin := events[0].Time
+	ts := func(t time.Time) float64 {
+		return float64(t.Sub(origin)) / float64(time.Microsecond)
+	}
+	span := func(name, cat string, tid int, start, end time.Time, args map[string]interface{}) *traceEvent {
+		dur := ts(end) - ts(start)
+		e := &traceEvent{Name: name, Cat: cat, Ph: "X", Ts: ts(start), Dur: &dur, Pid: 1, Tid: tid, Args: args}
+		trace.TraceEvents = append(trace.TraceEvents, e)
+		return e
+	}
+	threadName := func(tid int, name string) {
+		trace.TraceEvents = append(trace.TraceEvents, &traceEvent{Name: "thread_name", Ph: "M", Pid: 1, Tid: tid,
+			Args: map[string]interface{}{"name": name}})
+	}
+
+	// commands are told apart by id, as several can have the same name
+	commands := make(map[int]*traceCommand)
+	command := func(e *Event) *traceCommand {
+		c, ok := commands[e.CommandID]
+		if !ok {
+			c = &traceCommand{tid: len(commands) + 1}
+			commands[e.CommandID] = c
+			threadName(c.tid, e.Command)
+		}
+		return c
+	}
+	// end sets the duration of an open span, if there is one
+	end := func(e **traceEvent, t time.Time) {
+		if *e != nil {
+			dur := ts(t) - (*e).Ts
+			(*e).Dur = &dur
+			*e = nil
+		}
+	}
+
+	var runStart time.Time
+	threadName(0, "run")
+	for _, e := range events {
+		switch e.Type {
+		case EventRunStart:
+			runStart = e.Time
+		case EventRunEnd:
+			args := map[string]interface{}{"status": e.Status}
+			if e.Error != "" {
+				args["error"] = e.Error
+			}
+			span("run", "run", 0, runStart, e.Time, args)
+		case EventCommandStart:
+			command(e).start = e.Time
+		case EventAttemptStart:
+			c := command(e)
+			end(&c.attempt, e.Time)
+			end(&c.retry, e.Time)
+			c.attempt = span(fmt.Sprintf("attempt %d", e.Attempt), "attempt", c.tid, e.Time, e.Time, nil)
+			c.waiting = e.Time
+		case EventRetry:
+			c := command(e)
+			if c.attempt != nil {
+				c.attempt.Args = map[string]interface{}{"error": e.Error}
+			}
+			end(&c.attempt, e.Time)
+			c.retry = span("retry delay", "retry", c.tid, e.Time, e.Time, nil)
+		case EventExpectationMatched:
+			if e.CommandID == 0 {
+				// sessions aren't traced
+				continue
+			}
+			c := command(e)
+			span(fmt.Sprintf("wait for %q", e.Expectation), "wait", c.tid, c.waiting, e.Time, nil)
+			c.waiting = e.Time
+		case EventResponseSent:
+			if e.CommandID == 0 {
+				continue
+			}
+			c := command(e)
+			trace.TraceEvents = append(trace.TraceEvents, &traceEvent{Name: "respond", Cat: "response", Ph: "i",
+				Ts: ts(e.Time), Pid: 1, Tid: c.tid, S: "t", Args: map[string]interface{}{"text": e.Text}})
+			c.waiting = e.Time
+		case EventCommandEnd:
+			c := command(e)
+			end(&c.attempt, e.Time)
+			end(&c.retry, e.Time)
+			start := c.start
+			if start.IsZero() {
+				// skipped without being started
+				start = e.Time
+			}
+			args := map[string]interface{}{}
+			if e.Result != nil {
+				args["status"] = e.Result.Status
+				args["exit_code"] = e.Result.ExitCode
+				args["attempts"] = e.Result.Attempts
+				if e.Result.Err != nil {
+					args["error"] = e.Result.Err.Error()
+				}
+				if e.Result.SkipReason != "" {
+					args["skip_reason"] = e.Result.SkipReason
+				}
+			}
+			span(e.Command, "command", c.tid, start, e.Time, args)
+			c.start = time.Time{}
+		}
+	}
+	return json.NewEncoder(w).Encode(trace)
+}
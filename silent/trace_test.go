@@ -0,0 +1,128 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// traceSpans returns the events of a trace written by WriteTrace, by phase and then name
+func traceSpans(data []byte) map[string]map[string][]map[string]interface{} {
+	var trace struct {
+		TraceEvents []map[string]interface{} `json:"traceEvents"`
+	}
+	So(json.Unmarshal(data, &trace), ShouldBeNil)
+	spans := make(map[string]map[string][]map[string]interface{})
+	for _, e := range trace.TraceEvents {
+		ph, name := e["ph"].(string), e["name"].(string)
+		if spans[ph] == nil {
+			spans[ph] = make(map[string][]map[string]interface{})
+		}
+		spans[ph][name] = append(spans[ph][name], e)
+	}
+	return spans
+}
+
+func TestWriteTrace(t *testing.T) {
+	Convey("Given a run recorded with an EventRecorder", t, func() {
+		recorder := &EventRecorder{}
+		login := shellCmd("login", "printf 'User: '; read u; printf 'Password: '; read p; echo welcome")
+		login.Expectations = []*Expectation{
+			{Input: "User:", Output: "admin"},
+			{Input: "Password:", Output: "hunter2", Secret: true},
+		}
+		flaky := shellCmd("flaky", "echo nope; exit 2", "login")
+		flaky.Retries = 1
+		flaky.IgnoreErrors = true
+		r := &Runner{Commands: SilentCmds{login, flaky, shellCmd("skipped", "true")}, Skip: []string{"skipped"}}
+		So(r.Run(WithEventSink(recorder)), ShouldEqual, io.EOF)
+
+		out := &bytes.Buffer{}
+		So(WriteTrace(out, recorder.Events()), ShouldBeNil)
+		spans := traceSpans(out.Bytes())
+
+		Convey("Each command has its own named row", func() {
+			names := make(map[string]float64)
+			for _, e := range spans["M"]["thread_name"] {
+				names[e["args"].(map[string]interface{})["name"].(string)] = e["tid"].(float64)
+			}
+			So(names["run"], ShouldEqual, 0)
+			So(names, ShouldContainKey, "login")
+			So(names, ShouldContainKey, "flaky")
+			So(names["login"], ShouldNotEqual, names["flaky"])
+
+			So(len(spans["X"]["login"]), ShouldEqual, 1)
+			So(spans["X"]["login"][0]["tid"], ShouldEqual, names["login"])
+			So(spans["X"]["login"][0]["args"].(map[string]interface{})["status"], ShouldEqual, "ok")
+			So(spans["X"]["flaky"][0]["args"].(map[string]interface{})["status"], ShouldEqual, "ignored")
+			So(spans["X"]["skipped"][0]["args"].(map[string]interface{})["skip_reason"], ShouldEqual, "deselected")
+			So(spans["X"]["skipped"][0]["dur"], ShouldEqual, 0)
+		})
+
+		Convey("The run spans every command", func() {
+			run := spans["X"]["run"][0]
+			So(run["ts"], ShouldEqual, 0)
+			So(run["args"].(map[string]interface{})["status"], ShouldEqual, "ok")
+			flaky := spans["X"]["flaky"][0]
+			So(run["dur"].(float64), ShouldBeGreaterThanOrEqualTo, flaky["ts"].(float64)+flaky["dur"].(float64))
+		})
+
+		Convey("Waits for expectations end when they are matched, and the next starts once the response is sent", func() {
+			So(spans["X"], ShouldContainKey, `wait for "User:"`)
+			So(spans["X"], ShouldContainKey, `wait for "Password:"`)
+			user, password := spans["X"][`wait for "User:"`][0], spans["X"][`wait for "Password:"`][0]
+			So(user["cat"], ShouldEqual, "wait")
+			So(password["ts"], ShouldBeGreaterThanOrEqualTo, user["ts"].(float64)+user["dur"].(float64))
+
+			responses := spans["i"]["respond"]
+			So(len(responses), ShouldEqual, 2)
+			So(responses[0]["args"].(map[string]interface{})["text"], ShouldEqual, "admin\n")
+			So(responses[1]["args"].(map[string]interface{})["text"], ShouldEqual, secretMask+"\n")
+			So(responses[1]["ts"], ShouldBeGreaterThanOrEqualTo, password["ts"].(float64)+password["dur"].(float64))
+		})
+
+		Convey("Retries show each attempt and the delay between them", func() {
+			So(len(spans["X"]["attempt 1"]), ShouldEqual, 2)
+			So(len(spans["X"]["attempt 2"]), ShouldEqual, 1)
+			So(len(spans["X"]["retry delay"]), ShouldEqual, 1)
+			var failed map[string]interface{}
+			for _, a := range spans["X"]["attempt 1"] {
+				if a["tid"] == spans["X"]["flaky"][0]["tid"] {
+					failed = a
+				}
+			}
+			So(failed["args"].(map[string]interface{})["error"], ShouldEqual, "exit status 2")
+			delay := spans["X"]["retry delay"][0]
+			So(delay["ts"], ShouldAlmostEqual, failed["ts"].(float64)+failed["dur"].(float64), 0.01)
+			So(spans["X"]["attempt 2"][0]["ts"], ShouldAlmostEqual, delay["ts"].(float64)+delay["dur"].(float64), 0.01)
+		})
+	})
+
+	Convey("Commands running the same thing get rows of their own", t, func() {
+		recorder := &EventRecorder{}
+		first := shellCmd("", "echo hi")
+		first.Always = SilentCmds{shellCmd("", "echo hi")}
+		r := &Runner{Commands: SilentCmds{first, shellCmd("", "echo hi")}}
+		So(r.Run(WithEventSink(recorder)), ShouldEqual, io.EOF)
+
+		out := &bytes.Buffer{}
+		So(WriteTrace(out, recorder.Events()), ShouldBeNil)
+		spans := traceSpans(out.Bytes())
+		So(len(spans["M"]["thread_name"]), ShouldEqual, 4)
+		So(len(spans["X"]["echo hi"]), ShouldEqual, 3)
+		tids := make(map[float64]bool)
+		for _, e := range spans["X"]["attempt 1"] {
+			tids[e["tid"].(float64)] = true
+		}
+		So(len(tids), ShouldEqual, 3)
+	})
+
+	Convey("An empty trace is still a trace", t, func() {
+		out := &bytes.Buffer{}
+		So(WriteTrace(out, nil), ShouldBeNil)
+		So(out.String(), ShouldEqual, `{"traceEvents":[],"displayTimeUnit":"ms"}`+"\n")
+	})
+}
@@ -0,0 +1,138 @@
+package silent
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// ErrSignalUnsupported is returned by transports that have nothing to send a signal to
+var ErrSignalUnsupported = errors.New("transport does not support signals")
+
+// Transport is whatever a SilentCmd talks to. This is usually a child process, but can be anything that produces
+// output to match expectations against, and accepts responses as input.
+// A Transport is started once, and its readers and writer are only valid after Start has returned successfully
+type Transport interface {
+	// Start begins the session, starting a process or opening a connection
+	Start() error
+	// Stdout returns the reader carrying the transport's output. It returning io.EOF marks the end of the session
+	Stdout() io.Reader
+	// Stderr returns the reader carrying the transport's error output, or nil if it doesn't have any
+	Stderr() io.Reader
+	// Stdin returns the writer responses are written to. Closing it signals that no more input is coming
+	Stdin() io.WriteCloser
+	// Wait blocks until the session is over and releases its resources, returning an error if it
+	// ended unsuccessfully, e.g. an *exec.ExitError
+	Wait() error
+	// Signal sends a signal to the other end of the transport, if it supports it
+	Signal(sig os.Signal) error
+}
+
+// ExecTransport runs an *exec.Cmd with its stdin, stdout and stderr connected to pipes
+type ExecTransport struct {
+	Cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+}
+
+// Start implements Transport
+func (t *ExecTransport) Start() (err error) {
+	if t.stdin, err = t.Cmd.StdinPipe(); err != nil {
+		return
+	}
+	if t.stdout, err = t.Cmd.StdoutPipe(); err != nil {
+		return
+	}
+	if t.stderr, err = t.Cmd.StderrPipe(); err != nil {
+		return
+	}
+	return t.Cmd.Start()
+}
+
+// Stdout implements Transport
+func (t *ExecTransport) Stdout() io.Reader {
+	return t.stdout
+}
+
+// Stderr implements Transport
+func (t *ExecTransport) Stderr() io.Reader {
+	return t.stderr
+}
+
+// Stdin implements Transport
+func (t *ExecTransport) Stdin() io.WriteCloser {
+	return t.stdin
+}
+
+// Wait implements Transport
+func (t *ExecTransport) Wait() error {
+	return t.Cmd.Wait()
+}
+
+// Signal implements Transport
+func (t *ExecTransport) Signal(sig os.Signal) error {
+	if t.Cmd.Process == nil {
+		return errors.New("process has not been started")
+	}
+	return t.Cmd.Process.Signal(sig)
+}
+
+// StreamTransport talks to an already open io.ReadWriteCloser, such as a net.Conn or a serial port.
+// It has no separate error output and can't be signalled. Waiting closes the stream
+type StreamTransport struct {
+	Conn io.ReadWriteCloser
+}
+
+// Start implements Transport
+func (t *StreamTransport) Start() error {
+	if t.Conn == nil {
+		return errors.New("stream transport has no connection")
+	}
+	return nil
+}
+
+// Stdout implements Transport
+func (t *StreamTransport) Stdout() io.Reader {
+	return t.Conn
+}
+
+// Stderr implements Transport
+func (t *StreamTransport) Stderr() io.Reader {
+	return nil
+}
+
+// Stdin implements Transport. Closing it only closes the write side of the stream if the stream supports it,
+// such as a *net.TCPConn, and otherwise does nothing
+func (t *StreamTransport) Stdin() io.WriteCloser {
+	return streamWriter{t.Conn}
+}
+
+// Wait implements Transport
+func (t *StreamTransport) Wait() error {
+	return t.Conn.Close()
+}
+
+// Signal implements Transport
+func (t *StreamTransport) Signal(sig os.Signal) error {
+	return ErrSignalUnsupported
+}
+
+// streamWriter is the write side of a stream
+type streamWriter struct {
+	w io.Writer
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w streamWriter) Close() error {
+	if c, ok := w.w.(interface {
+		CloseWrite() error
+	}); ok {
+		return c.CloseWrite()
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package silent
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeDevice plays the other end of a conversation on conn, asking for a name and greeting whoever answers
+func fakeDevice(conn net.Conn) {
+	defer conn.Close()
+	conn.Write([]byte("login: "))
+	name, _ := bufio.NewReader(conn).ReadString('\n')
+	conn.Write([]byte("Welcome " + strings.TrimSpace(name) + "\n"))
+}
+
+func TestStreamTransport(t *testing.T) {
+	Convey("Expectations can be matched against any io.ReadWriteCloser", t, func() {
+		client, server := net.Pipe()
+		go fakeDevice(server)
+
+		s := NewSilentCmd()
+		s.Transport = &StreamTransport{Conn: client}
+		s.Expectations = []*Expectation{{Input: "login:", Output: "admin"}}
+		So(s.Exec(), ShouldEqual, io.EOF)
+		So(s.Output(), ShouldEqual, "login: Welcome admin\n")
+		So(s.Expectations, ShouldBeEmpty)
+		So(s.ExitCode(), ShouldEqual, 0)
+	})
+
+	Convey("A stream transport can't be signalled", t, func() {
+		client, _ := net.Pipe()
+		So((&StreamTransport{Conn: client}).Signal(nil), ShouldEqual, ErrSignalUnsupported)
+	})
+
+	Convey("A stream transport needs a connection", t, func() {
+		s := NewSilentCmd()
+		s.Transport = &StreamTransport{}
+		So(s.Exec(), ShouldNotBeNil)
+	})
+}
+
+func TestExecTransport(t *testing.T) {
+	Convey("Commands run through an explicit exec transport just like they do by default", t, func() {
+		s := NewSilentCmd()
+		s.Transport = &ExecTransport{Cmd: exec.Command("sh", "-c", "printf 'Name?'; read name; echo hi $name; exit 2")}
+		s.Expectations = []*Expectation{{Input: "Name?", Output: "Chris"}}
+		err := s.Exec()
+		So(err, ShouldNotBeNil)
+		So(err, ShouldNotEqual, io.EOF)
+		So(s.ExitCode(), ShouldEqual, 2)
+		So(s.Output(), ShouldEqual, "Name?hi Chris\n")
+	})
+
+	Convey("A command needs either a transport or an *exec.Cmd", t, func() {
+		So(NewSilentCmd().Exec().Error(), ShouldEqual, "s.Cmd must not be nil")
+	})
+}
@@ -0,0 +1,228 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// askAttempts is how many times a question is asked before an invalid answer is given up on
+const askAttempts = 3
+
+// SecretAsker is implemented by Uis that can ask for an answer without echoing it, such as a password
+type SecretAsker interface {
+	AskSecret(string) (string, error)
+}
+
+// Validator checks an answer, returning an error explaining what is wrong with it
+type Validator func(answer string) error
+
+// NotEmpty is a Validator rejecting empty answers
+func NotEmpty(answer string) error {
+	if strings.TrimSpace(answer) == "" {
+		return errors.New("an answer is required")
+	}
+	return nil
+}
+
+// MatchesRegexp returns a Validator accepting answers matching the regular expression pattern
+func MatchesRegexp(pattern string) Validator {
+	re := regexp.MustCompile(pattern)
+	return func(answer string) error {
+		if !re.MatchString(answer) {
+			return fmt.Errorf("%q must match %s", answer, pattern)
+		}
+		return nil
+	}
+}
+
+// OneOf returns a Validator accepting only the given choices
+func OneOf(choices ...string) Validator {
+	return func(answer string) error {
+		for _, c := range choices {
+			if answer == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q must be one of %s", answer, strings.Join(choices, ", "))
+	}
+}
+
+// AskSecret asks u for an answer without echoing it. An error is returned if u can't do that, rather than
+// risking showing the secret
+func AskSecret(u Ui, query string, validators ...Validator) (string, error) {
+	asker, err := secretAsker(u)
+	if err != nil {
+		return "", err
+	}
+	// secrets are used exactly as typed
+	return askUntilValid(u, asker.AskSecret, query, "", validators)
+}
+
+// secretAsker returns u as a SecretAsker, or an error if it isn't one
+func secretAsker(u Ui) (SecretAsker, error) {
+	asker, ok := u.(SecretAsker)
+	if !ok {
+		return nil, fmt.Errorf("%T can't ask for secrets", u)
+	}
+	return asker, nil
+}
+
+// AskWithDefault asks u, showing def and using it if the answer is empty. The answer is checked by each of
+// validators, and the question is asked again if any of them reject it
+func AskWithDefault(u Ui, query, def string, validators ...Validator) (string, error) {
+	if def != "" {
+		query = fmt.Sprintf("%s [%s]", query, def)
+	}
+	return askUntilValid(u, trimmed(u.Ask), query, def, validators)
+}
+
+// AskChoice asks u to pick one of choices, which may be answered with the choice itself or its number
+// counting from 1. An empty answer picks def, if it isn't empty
+func AskChoice(u Ui, query string, choices []string, def string) (string, error) {
+	if len(choices) == 0 {
+		return "", errors.New("there are no choices to ask about")
+	}
+	for i, c := range choices {
+		u.Message(fmt.Sprintf("  %d) %s", i+1, c))
+	}
+	// numbered answers are turned into choices before they're validated
+	var numbered []string
+	for i := range choices {
+		numbered = append(numbered, fmt.Sprint(i+1))
+	}
+	answer, err := AskWithDefault(u, query, def, OneOf(append(numbered, choices...)...))
+	if err != nil {
+		return "", err
+	}
+	for i, n := range numbered {
+		if answer == n {
+			return choices[i], nil
+		}
+	}
+	return answer, nil
+}
+
+// AskConfirm asks u a yes or no question, returning def if the answer is empty
+func AskConfirm(u Ui, query string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := askUntilValid(u, trimmed(u.Ask), fmt.Sprintf("%s [%s]", query, hint), "", []Validator{
+		OneOf("", "y", "Y", "yes", "Yes", "YES", "n", "N", "no", "No", "NO"),
+	})
+	if err != nil {
+		return false, err
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// askUntilValid asks query with ask until validators accept the answer, showing why they didn't on u.
+// Empty answers are replaced by def, if it isn't empty
+func askUntilValid(u Ui, ask func(string) (string, error), query, def string, validators []Validator) (string, error) {
+	var invalid error
+	for attempt := 0; attempt < askAttempts; attempt++ {
+		answer, err := ask(query)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		invalid = nil
+		for _, validate := range validators {
+			if invalid = validate(answer); invalid != nil {
+				break
+			}
+		}
+		if invalid == nil {
+			return answer, nil
+		}
+		u.Error(invalid.Error())
+	}
+	return "", fmt.Errorf("no valid answer after %d attempts: %s", askAttempts, invalid)
+}
+
+// trimmed returns ask with spaces trimmed from around its answers
+func trimmed(ask func(string) (string, error)) func(string) (string, error) {
+	return func(query string) (string, error) {
+		answer, err := ask(query)
+		return strings.TrimSpace(answer), err
+	}
+}
+
+// readLine reads a single line from r, one byte at a time so that nothing after it is consumed.
+// The newline, and a carriage return before it, are not returned
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// disableEcho turns off echoing on r if it is a terminal, returning a function turning it back on
+func disableEcho(r io.Reader) (restore func(), err error) {
+	f, ok := r.(*os.File)
+	if !ok || !IsTerminal(f) {
+		return func() {}, nil
+	}
+	if err := stty(f, "-echo"); err != nil {
+		return nil, fmt.Errorf("can't turn off echo to ask for a secret: %s", err)
+	}
+	return func() { stty(f, "echo") }, nil
+}
+
+// stty changes the settings of the terminal f, the same way on every unix
+func stty(f *os.File, args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	return cmd.Run()
+}
+
+func (u *ColoredUi) AskSecret(query string) (string, error) {
+	asker, err := secretAsker(u.Ui)
+	if err != nil {
+		return "", err
+	}
+	return asker.AskSecret(u.colorize(query, u.Color, true))
+}
+
+func (u *TargettedUi) AskSecret(query string) (string, error) {
+	asker, err := secretAsker(u.Ui)
+	if err != nil {
+		return "", err
+	}
+	return asker.AskSecret(u.prefixLines(true, query))
+}
+
+func (u *MultiUi) AskSecret(query string) (string, error) {
+	for _, sink := range u.Sinks {
+		if sink.Interactive {
+			asker, err := secretAsker(sink.Ui)
+			if err != nil {
+				return "", err
+			}
+			return asker.AskSecret(query)
+		}
+	}
+	return "", errors.New("no interactive UI to ask")
+}
@@ -0,0 +1,243 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// answering returns a test BasicUi whose Reader holds answers, one per line
+func answering(answers ...string) *BasicUi {
+	u := testUi()
+	u.Reader = bytes.NewBufferString(strings.Join(answers, "\n") + "\n")
+	return u
+}
+
+func TestBasicUi_Ask(t *testing.T) {
+	u := answering("two words", "next")
+
+	answer, err := u.Ask("what?")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "two words" {
+		t.Fatalf("bad: %#v", answer)
+	}
+	if out := readWriter(u); out != "what? " {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	answer, err = u.Ask("")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "next" {
+		t.Fatalf("bad: %#v", answer)
+	}
+}
+
+func TestBasicUi_ImplSecretAsker(t *testing.T) {
+	var raw interface{}
+	raw = &BasicUi{}
+	if _, ok := raw.(SecretAsker); !ok {
+		t.Fatalf("BasicUi must implement SecretAsker")
+	}
+}
+
+func TestAskSecret(t *testing.T) {
+	u := answering(" s3cret ")
+
+	answer, err := AskSecret(u, "password:")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != " s3cret " {
+		t.Fatalf("secrets must not be trimmed: %#v", answer)
+	}
+	if out := readWriter(u); strings.Contains(out, "s3cret") {
+		t.Fatalf("secret was echoed: %#v", out)
+	}
+
+	if _, err := AskSecret(&MachineReadableUi{Writer: new(bytes.Buffer)}, "password:"); err == nil {
+		t.Fatalf("MachineReadableUi can't ask for secrets")
+	}
+}
+
+func TestAskSecret_wrapped(t *testing.T) {
+	u := answering("a", "b", "c")
+	uis := []Ui{
+		&ColoredUi{Color: UiColorYellow, Mode: ColorNever, Ui: u},
+		&TargettedUi{Target: "foo", Ui: u},
+		&MultiUi{Sinks: []*Sink{{Ui: u, Interactive: true}}},
+	}
+	for i, wrapped := range uis {
+		answer, err := AskSecret(wrapped, "password:")
+		if err != nil {
+			t.Fatalf("%T err: %s", wrapped, err)
+		}
+		if expected := string('a' + byte(i)); answer != expected {
+			t.Fatalf("%T bad: %#v", wrapped, answer)
+		}
+	}
+
+	wrapped := &ColoredUi{Ui: &MachineReadableUi{Writer: new(bytes.Buffer)}}
+	if _, err := AskSecret(wrapped, "password:"); err == nil {
+		t.Fatalf("ColoredUi wrapping a MachineReadableUi can't ask for secrets")
+	}
+	if _, err := AskSecret(&MultiUi{}, "password:"); err == nil {
+		t.Fatalf("MultiUi without an interactive sink can't ask for secrets")
+	}
+}
+
+func TestAskWithDefault(t *testing.T) {
+	u := answering("", "  bar  ")
+
+	answer, err := AskWithDefault(u, "name?", "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "foo" {
+		t.Fatalf("bad: %#v", answer)
+	}
+	if out := readWriter(u); out != "name? [foo] " {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	answer, err = AskWithDefault(u, "name?", "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "bar" {
+		t.Fatalf("bad: %#v", answer)
+	}
+}
+
+func TestAskWithDefault_validators(t *testing.T) {
+	u := answering("", "abc", "42")
+
+	answer, err := AskWithDefault(u, "port?", "", NotEmpty, MatchesRegexp(`^\d+$`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "42" {
+		t.Fatalf("bad: %#v", answer)
+	}
+	errors := readErrorWriter(u)
+	if !strings.Contains(errors, "an answer is required") || !strings.Contains(errors, `"abc" must match`) {
+		t.Fatalf("bad: %#v", errors)
+	}
+
+	u = answering("a", "b", "c", "d")
+	if _, err := AskWithDefault(u, "port?", "", MatchesRegexp(`^\d+$`)); err == nil {
+		t.Fatalf("should give up after %d invalid answers", askAttempts)
+	}
+	if answer, _ := u.Ask(""); answer != "d" {
+		t.Fatalf("should have stopped reading after %d answers, next was %#v", askAttempts, answer)
+	}
+}
+
+func TestAskChoice(t *testing.T) {
+	u := answering("2", "blue", "", "purple", "3")
+	choices := []string{"red", "green", "blue"}
+
+	answer, err := AskChoice(u, "color?", choices, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "green" {
+		t.Fatalf("bad: %#v", answer)
+	}
+	if out := readWriter(u); out != "  1) red\n  2) green\n  3) blue\ncolor? " {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	answer, err = AskChoice(u, "color?", choices, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "blue" {
+		t.Fatalf("bad: %#v", answer)
+	}
+
+	answer, err = AskChoice(u, "color?", choices, "red")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "red" {
+		t.Fatalf("bad: %#v", answer)
+	}
+
+	answer, err = AskChoice(u, "color?", choices, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answer != "blue" {
+		t.Fatalf("bad: %#v", answer)
+	}
+	if errors := readErrorWriter(u); !strings.Contains(errors, `"purple" must be one of`) {
+		t.Fatalf("bad: %#v", errors)
+	}
+
+	if _, err := AskChoice(u, "color?", nil, ""); err == nil {
+		t.Fatalf("should fail without choices")
+	}
+}
+
+func TestAskConfirm(t *testing.T) {
+	cases := []struct {
+		answer   string
+		def      bool
+		expected bool
+	}{
+		{"", true, true},
+		{"", false, false},
+		{"y", false, true},
+		{"Yes", false, true},
+		{"n", true, false},
+		{"NO", true, false},
+	}
+	for _, c := range cases {
+		u := answering(c.answer)
+		actual, err := AskConfirm(u, "sure?", c.def)
+		if err != nil {
+			t.Fatalf("%#v err: %s", c.answer, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("%#v with default %t: bad: %t", c.answer, c.def, actual)
+		}
+	}
+
+	u := answering("maybe", "y")
+	actual, err := AskConfirm(u, "sure?", false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !actual {
+		t.Fatalf("bad: %t", actual)
+	}
+	if out := readWriter(u); out != "sure? [y/N] sure? [y/N] " {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	r := bytes.NewBufferString("one\r\ntwo\nthree")
+
+	for _, expected := range []string{"one", "two"} {
+		line, err := readLine(r)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if line != expected {
+			t.Fatalf("bad: %#v", line)
+		}
+	}
+
+	line, err := readLine(r)
+	if err == nil {
+		t.Fatalf("the last line has no newline, so should end with an error")
+	}
+	if line != "three" {
+		t.Fatalf("bad: %#v", line)
+	}
+}
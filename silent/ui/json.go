@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSONUi is a UI that writes everything as JSON objects, one per line, to the given Writer.
+// Each object has a "time" in RFC 3339 format and a "type" of say, message, error or machine. Say, message
+// and error objects have a "message", machine objects have a "category", "args" and, if set, a "target".
+// It is safe to be called from multiple goroutines
+type JSONUi struct {
+	Writer io.Writer
+	l      sync.Mutex
+}
+
+// JSONUiLine is a line written by a JSONUi
+type JSONUiLine struct {
+	Time     string   `json:"time"`
+	Type     string   `json:"type"`
+	Message  string   `json:"message,omitempty"`
+	Target   string   `json:"target,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Args     []string `json:"args,omitempty"`
+}
+
+func (u *JSONUi) Ask(query string) (string, error) {
+	return "", errors.New("json UI can't ask")
+}
+
+func (u *JSONUi) Say(message string) {
+	u.write(&JSONUiLine{Type: "say", Message: message})
+}
+
+func (u *JSONUi) Message(message string) {
+	u.write(&JSONUiLine{Type: "message", Message: message})
+}
+
+func (u *JSONUi) Error(message string) {
+	u.write(&JSONUiLine{Type: "error", Message: message})
+}
+
+func (u *JSONUi) Machine(category string, args ...string) {
+	// Determine if we have a target, as set by TargettedUi
+	target := ""
+	if commaIdx := strings.Index(category, ","); commaIdx > -1 {
+		target = category[0:commaIdx]
+		category = category[commaIdx+1:]
+	}
+	u.write(&JSONUiLine{Type: "machine", Target: target, Category: category, Args: args})
+}
+
+func (u *JSONUi) write(line *JSONUiLine) {
+	line.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(line)
+	if err != nil {
+		// only strings are marshalled, so this can't happen
+		panic(err)
+	}
+
+	u.l.Lock()
+	defer u.l.Unlock()
+	// errors are ignored, like MachineReadableUi ignores a closed pipe
+	u.Writer.Write(append(data, '\n'))
+}
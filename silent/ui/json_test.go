@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readJSONLines(t *testing.T, buf *bytes.Buffer) []JSONUiLine {
+	var lines []JSONUiLine
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line JSONUiLine
+		if err := json.Unmarshal([]byte(l), &line); err != nil {
+			t.Fatalf("bad line %q: %s", l, err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, line.Time); err != nil {
+			t.Fatalf("bad time %q: %s", line.Time, err)
+		}
+		line.Time = ""
+		lines = append(lines, line)
+	}
+	buf.Reset()
+	return lines
+}
+
+func TestJSONUi_ImplUi(t *testing.T) {
+	var raw interface{}
+	raw = &JSONUi{}
+	if _, ok := raw.(Ui); !ok {
+		t.Fatalf("JSONUi must implement Ui")
+	}
+}
+
+func TestJSONUi(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &JSONUi{Writer: buf}
+
+	ui.Say("foo, \"bar\"\nbaz")
+	ui.Message("message")
+	ui.Error("error")
+	lines := readJSONLines(t, buf)
+	expected := []JSONUiLine{
+		{Type: "say", Message: "foo, \"bar\"\nbaz"},
+		{Type: "message", Message: "message"},
+		{Type: "error", Message: "error"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("bad: %#v", lines)
+	}
+	for i := range expected {
+		if lines[i].Type != expected[i].Type || lines[i].Message != expected[i].Message {
+			t.Fatalf("bad line %d: %#v", i, lines[i])
+		}
+	}
+
+	targetted := &TargettedUi{Target: "install", Ui: ui}
+	targetted.Machine("artifact", "a,b", "c")
+	lines = readJSONLines(t, buf)
+	line := lines[0]
+	if line.Type != "machine" || line.Target != "install" || line.Category != "artifact" ||
+		len(line.Args) != 2 || line.Args[0] != "a,b" || line.Args[1] != "c" {
+		t.Fatalf("bad: %#v", line)
+	}
+
+	if _, err := ui.Ask("name?"); err == nil {
+		t.Fatal("should error")
+	}
+}
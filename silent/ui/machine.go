@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MachineRecord is one line of the output written by MachineReadableUi
+type MachineRecord struct {
+	Time time.Time
+	// Target is the target set by TargettedUi, if any
+	Target string
+	// Type is the category passed to Machine, e.g. "ui" for Say, Message and Error
+	Type string
+	// Data are the args passed to Machine, e.g. "say" and the message for Say
+	Data []string
+}
+
+// machineUnescaper reverses the escaping done by MachineReadableUi.Machine
+var machineUnescaper = strings.NewReplacer("%!(PACKER_COMMA)", ",", "\\r", "\r", "\\n", "\n")
+
+// ParseMachineLine parses a single line written by MachineReadableUi.Machine, with or without its newline.
+//
+// The format can't tell a call without args from a call with a single empty arg, both are read as having no Data.
+// Backslashes aren't escaped either, so an arg containing a literal \n is read back as a newline
+func ParseMachineLine(line string) (*MachineRecord, error) {
+	line = strings.TrimSuffix(line, "\n")
+	fields := strings.SplitN(line, ",", 4)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("machine readable line %q needs at least 4 fields", line)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("machine readable line %q has an invalid timestamp: %s", line, err)
+	}
+
+	record := &MachineRecord{Time: time.Unix(seconds, 0).UTC(), Target: fields[1], Type: fields[2]}
+	if fields[3] != "" {
+		for _, arg := range strings.Split(fields[3], ",") {
+			record.Data = append(record.Data, machineUnescaper.Replace(arg))
+		}
+	}
+	return record, nil
+}
+
+// MachineReader reads the records written by a MachineReadableUi from a stream
+type MachineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewMachineReader returns a MachineReader reading from r
+func NewMachineReader(r io.Reader) *MachineReader {
+	scanner := bufio.NewScanner(r)
+	// lines can carry whole chunks of command output
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &MachineReader{scanner: scanner}
+}
+
+// Read returns the next record, or io.EOF once the stream has ended. Blank lines are skipped, and errors
+// include the line number
+func (r *MachineReader) Read() (*MachineRecord, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSuffix(r.scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		record, err := ParseMachineLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", r.line, err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// ReadAll returns every remaining record
+func (r *MachineReader) ReadAll() ([]*MachineRecord, error) {
+	var records []*MachineRecord
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
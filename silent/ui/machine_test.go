@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMachineReader_roundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &MachineReadableUi{Writer: buf}
+	targetted := &TargettedUi{Target: "mitchellh", Ui: ui}
+
+	args := []string{"foo,bar", "line\r\nnext\n", ""}
+	start := time.Now().UTC().Truncate(time.Second)
+	ui.Machine("foo", "bar", "baz")
+	targetted.Machine("artifact", args...)
+	ui.Say("hello, world")
+	ui.Machine("empty")
+
+	if args[0] != "foo,bar" {
+		t.Fatalf("Machine must not change its args: %#v", args)
+	}
+
+	records, err := NewMachineReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*MachineRecord{
+		{Type: "foo", Data: []string{"bar", "baz"}},
+		{Target: "mitchellh", Type: "artifact", Data: args},
+		{Type: "ui", Data: []string{"say", "hello, world"}},
+		{Type: "empty"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("bad: %#v", records)
+	}
+	for i, record := range records {
+		if record.Time.Before(start) || record.Time.After(time.Now()) {
+			t.Fatalf("bad time %d: %s", i, record.Time)
+		}
+		record.Time = time.Time{}
+		if !reflect.DeepEqual(record, expected[i]) {
+			t.Fatalf("bad record %d: %#v", i, record)
+		}
+	}
+}
+
+func TestParseMachineLine(t *testing.T) {
+	record, err := ParseMachineLine("1480603956,web,ui,error,it broke%!(PACKER_COMMA) badly\\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &MachineRecord{
+		Time:   time.Date(2016, 12, 1, 14, 52, 36, 0, time.UTC),
+		Target: "web",
+		Type:   "ui",
+		Data:   []string{"error", "it broke, badly\n"},
+	}
+	if !reflect.DeepEqual(record, expected) {
+		t.Fatalf("bad: %#v", record)
+	}
+
+	for _, line := range []string{"", "1480603956,web,ui", "yesterday,web,ui,say"} {
+		if _, err := ParseMachineLine(line); err == nil {
+			t.Fatalf("should error: %q", line)
+		}
+	}
+}
+
+func TestMachineReader_errors(t *testing.T) {
+	r := NewMachineReader(strings.NewReader("1480603956,,ui,say,hi\n\nnot machine readable\n"))
+	if _, err := r.Read(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.Read()
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// ColorMode decides whether a ColoredUi uses colors
+type ColorMode int
+
+const (
+	// ColorAuto uses colors unless NO_COLOR or PACKER_NO_COLOR is set, TERM is dumb or output isn't a terminal
+	ColorAuto ColorMode = iota
+	// ColorAlways uses colors no matter what
+	ColorAlways
+	// ColorNever never uses colors
+	ColorNever
+)
+
+// ParseColorMode converts "auto", "always" or "never" to a ColorMode
+func ParseColorMode(mode string) (ColorMode, error) {
+	switch mode {
+	case "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	}
+	return ColorAuto, fmt.Errorf("unknown color mode %q, must be auto, always or never", mode)
+}
+
+// IsTerminal returns true if f is a terminal, rather than a file, pipe or another device such as /dev/null
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f)
+}
+
+// TerminalWidth returns the number of columns of the terminal f, or 0 if f isn't a terminal or its size
+// can't be found
+func TerminalWidth(f *os.File) int {
+	if !IsTerminal(f) {
+		return 0
+	}
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(string(out), &rows, &cols); err != nil {
+		return 0
+	}
+	return cols
+}
+
+// Kinds of Ui that can be made with New
+const (
+	KindColored = "colored"
+	KindPlain   = "plain"
+	KindMachine = "machine"
+	KindJSON    = "json"
+)
+
+// New returns a Ui of the given kind reading from in and writing to out and errOut: a ColoredUi using color,
+// a plain BasicUi, a MachineReadableUi or a JSONUi. The machine and json kinds only write to out
+func New(kind string, color ColorMode, in io.Reader, out, errOut io.Writer) (Ui, error) {
+	basic := &BasicUi{Reader: in, Writer: out, ErrorWriter: errOut}
+	switch kind {
+	case KindColored:
+		return &ColoredUi{Color: UiColorGreen, ErrorColor: UiColorRed, Ui: basic, Mode: color}, nil
+	case KindPlain:
+		return basic, nil
+	case KindMachine:
+		return &MachineReadableUi{Writer: out}, nil
+	case KindJSON:
+		return &JSONUi{Writer: out}, nil
+	}
+	return nil, fmt.Errorf("unknown ui %q, must be colored, plain, machine or json", kind)
+}
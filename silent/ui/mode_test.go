@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseColorMode(t *testing.T) {
+	for s, expected := range map[string]ColorMode{"auto": ColorAuto, "always": ColorAlways, "never": ColorNever} {
+		mode, err := ParseColorMode(s)
+		if err != nil || mode != expected {
+			t.Fatalf("bad %s: %d %s", s, mode, err)
+		}
+	}
+	if _, err := ParseColorMode("sometimes"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestColoredUi_modes(t *testing.T) {
+	bufferUi := testUi()
+	ui := &ColoredUi{Color: UiColorYellow, ErrorColor: UiColorRed, Ui: bufferUi, Mode: ColorNever}
+	ui.Say("foo")
+	if result := readWriter(bufferUi); result != "foo\n" {
+		t.Fatalf("invalid output: %s", result)
+	}
+
+	oldenv := os.Getenv("NO_COLOR")
+	os.Setenv("NO_COLOR", "1")
+	defer os.Setenv("NO_COLOR", oldenv)
+
+	ui.Mode = ColorAlways
+	ui.Say("foo")
+	if result := readWriter(bufferUi); result != "\033[1;33mfoo\033[0m\n" {
+		t.Fatalf("invalid output: %s", result)
+	}
+
+	ui.Mode = ColorAuto
+	ui.Say("foo")
+	if result := readWriter(bufferUi); result != "foo\n" {
+		t.Fatalf("invalid output: %s", result)
+	}
+}
+
+func TestColoredUi_dumbTerminal(t *testing.T) {
+	bufferUi := testUi()
+	ui := &ColoredUi{Color: UiColorYellow, ErrorColor: UiColorRed, Ui: bufferUi}
+
+	oldenv := os.Getenv("TERM")
+	os.Setenv("TERM", "dumb")
+	defer os.Setenv("TERM", oldenv)
+
+	ui.Say("foo")
+	if result := readWriter(bufferUi); result != "foo\n" {
+		t.Fatalf("invalid output: %s", result)
+	}
+}
+
+func TestColoredUi_notTerminal(t *testing.T) {
+	f, err := ioutil.TempFile("", "ui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if IsTerminal(f) {
+		t.Fatal("a file is not a terminal")
+	}
+	if width := TerminalWidth(f); width != 0 {
+		t.Fatalf("a file has no width: %d", width)
+	}
+	ui := &ColoredUi{Color: UiColorYellow, ErrorColor: UiColorRed, Ui: &BasicUi{Writer: f}}
+	ui.Say("foo")
+	data, _ := ioutil.ReadFile(f.Name())
+	if string(data) != "foo\n" {
+		t.Fatalf("invalid output: %q", data)
+	}
+}
+
+func TestNew(t *testing.T) {
+	out := new(bytes.Buffer)
+	for kind, check := range map[string]func(Ui) bool{
+		KindColored: func(u Ui) bool { c, ok := u.(*ColoredUi); return ok && c.Mode == ColorNever },
+		KindPlain:   func(u Ui) bool { _, ok := u.(*BasicUi); return ok },
+		KindMachine: func(u Ui) bool { _, ok := u.(*MachineReadableUi); return ok },
+		KindJSON:    func(u Ui) bool { _, ok := u.(*JSONUi); return ok },
+	} {
+		u, err := New(kind, ColorNever, nil, out, out)
+		if err != nil || !check(u) {
+			t.Fatalf("bad %s: %#v %s", kind, u, err)
+		}
+	}
+	if _, err := New("fancy", ColorAuto, nil, out, out); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestIsTerminal_devNull(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if IsTerminal(f) {
+		t.Fatalf("%s is not a terminal", os.DevNull)
+	}
+}
@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is how important a message shown on a Ui is, from Message (details) through Say to Error
+type Level int
+
+const (
+	LevelMessage Level = iota
+	LevelSay
+	LevelError
+)
+
+var levelNames = []string{"message", "say", "error"}
+
+func (l Level) String() string {
+	if l < LevelMessage || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel converts "message", "say" or "error" to a Level
+func ParseLevel(level string) (Level, error) {
+	for i, name := range levelNames {
+		if level == name {
+			return Level(i), nil
+		}
+	}
+	return LevelMessage, fmt.Errorf("unknown ui level %q, must be message, say or error", level)
+}
+
+// Sink is one of the Uis a MultiUi writes to
+type Sink struct {
+	Ui Ui
+	// Level is the least important level passed on to Ui. Machine output is always passed on
+	Level Level
+	// Interactive sinks can be asked questions
+	Interactive bool
+}
+
+// MultiUi is a UI that writes to several other UIs at once, such as the console and a log file.
+// Questions are asked by the first interactive sink
+type MultiUi struct {
+	Sinks []*Sink
+}
+
+func (u *MultiUi) Ask(query string) (string, error) {
+	for _, sink := range u.Sinks {
+		if sink.Interactive {
+			return sink.Ui.Ask(query)
+		}
+	}
+	return "", errors.New("no interactive UI to ask")
+}
+
+func (u *MultiUi) Say(message string) {
+	for _, sink := range u.sinks(LevelSay) {
+		sink.Ui.Say(message)
+	}
+}
+
+func (u *MultiUi) Message(message string) {
+	for _, sink := range u.sinks(LevelMessage) {
+		sink.Ui.Message(message)
+	}
+}
+
+func (u *MultiUi) Error(message string) {
+	for _, sink := range u.sinks(LevelError) {
+		sink.Ui.Error(message)
+	}
+}
+
+func (u *MultiUi) Machine(t string, args ...string) {
+	for _, sink := range u.Sinks {
+		sink.Ui.Machine(t, args...)
+	}
+}
+
+// sinks returns the sinks that want messages at level
+func (u *MultiUi) sinks(level Level) []*Sink {
+	var sinks []*Sink
+	for _, sink := range u.Sinks {
+		if level >= sink.Level {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
+
+// ParseSinkSpec parses a sink given on the command line as KIND[:PATH][@LEVEL], such as "plain:install.log" or
+// "machine:-@say". kind is one of the kinds New accepts, path is empty or "-" for stdout, and level defaults to
+// LevelMessage
+func ParseSinkSpec(spec string) (kind, path string, level Level, err error) {
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		if level, err = ParseLevel(spec[i+1:]); err != nil {
+			return "", "", level, fmt.Errorf("invalid ui sink %q: %s", spec, err)
+		}
+		spec = spec[:i]
+	}
+	kind = spec
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, path = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case KindColored, KindPlain, KindMachine, KindJSON:
+	default:
+		return "", "", level, fmt.Errorf("invalid ui sink %q: unknown ui %q, must be colored, plain, machine or json",
+			spec, kind)
+	}
+	return kind, path, level, nil
+}
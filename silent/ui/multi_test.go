@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMultiUi_ImplUi(t *testing.T) {
+	var raw interface{}
+	raw = &MultiUi{}
+	if _, ok := raw.(Ui); !ok {
+		t.Fatalf("MultiUi must implement Ui")
+	}
+}
+
+func TestMultiUi(t *testing.T) {
+	everything, errorsOnly := testUi(), testUi()
+	machine := new(bytes.Buffer)
+	ui := &MultiUi{Sinks: []*Sink{
+		{Ui: everything},
+		{Ui: errorsOnly, Level: LevelError},
+		{Ui: &MachineReadableUi{Writer: machine}, Level: LevelSay},
+	}}
+
+	ui.Message("detail")
+	ui.Say("headline")
+	ui.Error("broken")
+	ui.Machine("artifact", "id")
+
+	if actual := readWriter(everything); actual != "detail\nheadline\n" {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual := readErrorWriter(everything); actual != "broken\n" {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual := readWriter(errorsOnly); actual != "" {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual := readErrorWriter(errorsOnly); actual != "broken\n" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	records, err := NewMachineReader(machine).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, r := range records {
+		lines = append(lines, r.Type+":"+strings.Join(r.Data, ","))
+	}
+	expected := "ui:say,headline ui:error,broken artifact:id"
+	if strings.Join(lines, " ") != expected {
+		t.Fatalf("bad: %#v", lines)
+	}
+}
+
+func TestMultiUi_Ask(t *testing.T) {
+	quiet, asker := testUi(), testUi()
+	asker.Reader.(*bytes.Buffer).WriteString("Chris\n")
+	ui := &MultiUi{Sinks: []*Sink{{Ui: quiet}, {Ui: asker, Interactive: true}}}
+
+	answer, err := ui.Ask("Name?")
+	if err != nil || answer != "Chris" {
+		t.Fatalf("bad: %#v %s", answer, err)
+	}
+	if actual := readWriter(quiet); actual != "" {
+		t.Fatalf("only the asking sink should show the question: %#v", actual)
+	}
+
+	ui = &MultiUi{Sinks: []*Sink{{Ui: quiet}}}
+	if _, err := ui.Ask("Name?"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestParseSinkSpec(t *testing.T) {
+	cases := []struct {
+		spec, kind, path string
+		level            Level
+	}{
+		{"colored", KindColored, "", LevelMessage},
+		{"plain:/var/log/install.log", KindPlain, "/var/log/install.log", LevelMessage},
+		{"machine:-@say", KindMachine, "-", LevelSay},
+		{"json@error", KindJSON, "", LevelError},
+		{"plain:C:\\logs\\install.log", KindPlain, "C:\\logs\\install.log", LevelMessage},
+	}
+	for _, c := range cases {
+		kind, path, level, err := ParseSinkSpec(c.spec)
+		if err != nil || kind != c.kind || path != c.path || level != c.level {
+			t.Fatalf("bad %s: %s %s %s %v", c.spec, kind, path, level, err)
+		}
+	}
+	for _, spec := range []string{"fancy", "plain:x@loud", ""} {
+		if _, _, _, err := ParseSinkSpec(spec); err == nil {
+			t.Fatalf("should error: %s", spec)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package ui
+
+import (
+	"os"
+	"syscall"
+)
+
+// isTerminal asks for f's console mode, which only a console has
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
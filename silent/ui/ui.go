@@ -44,11 +44,13 @@ type ColoredUi struct {
 	Color      UiColor
 	ErrorColor UiColor
 	Ui         Ui
+	// Mode decides whether colors are used, by default they are when it looks like they'll work
+	Mode ColorMode
 }
 
 // NewColoredUi returns a wrapped BasicUi(bufferui)
 func NewColoredUi() *ColoredUi {
-	return &ColoredUi{UiColorGreen, UiColorRed, BufferUi()}
+	return &ColoredUi{Color: UiColorGreen, ErrorColor: UiColorRed, Ui: BufferUi()}
 }
 
 // BufferUi returns a new BasicUi with its Reader, Writer, and ErrorWriter initialized to a new(bytes.Buffer)
@@ -141,11 +143,25 @@ func (u *ColoredUi) colorize(message string, color UiColor, bold bool) string {
 }
 
 func (u *ColoredUi) supportsColors() bool {
-	// Never use colors if we have this environmental variable
-	if os.Getenv("PACKER_NO_COLOR") != "" {
+	switch u.Mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	// Never use colors if we have one of these environmental variables, see https://no-color.org
+	if os.Getenv("PACKER_NO_COLOR") != "" || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
 		return false
 	}
 
+	// Or if we're writing to a file or pipe rather than a terminal
+	if basic, ok := u.Ui.(*BasicUi); ok {
+		if f, ok := basic.Writer.(*os.File); ok && !IsTerminal(f) {
+			return false
+		}
+	}
+
 	// For now, on non-Windows machine, just assume it does
 	if runtime.GOOS != "windows" {
 		return true
@@ -196,6 +212,16 @@ func (u *TargettedUi) prefixLines(arrow bool, message string) string {
 }
 
 func (rw *BasicUi) Ask(query string) (string, error) {
+	return rw.ask(query, false)
+}
+
+// AskSecret is like Ask, but doesn't echo the answer when reading from a terminal
+func (rw *BasicUi) AskSecret(query string) (string, error) {
+	return rw.ask(query, true)
+}
+
+// ask asks query, reading a whole line as the answer. If secret is true, the answer is never echoed or logged
+func (rw *BasicUi) ask(query string, secret bool) (string, error) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
@@ -214,10 +240,22 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 		}
 	}
 
+	if secret {
+		restore, err := disableEcho(rw.Reader)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			restore()
+			// the user's newline wasn't echoed either
+			fmt.Fprintln(rw.Writer)
+		}()
+	}
+
 	result := make(chan string, 1)
 	go func() {
-		var line string
-		if _, err := fmt.Fscanln(rw.Reader, &line); err != nil {
+		line, err := readLine(rw.Reader)
+		if err != nil && err != io.EOF {
 			log.Printf("ui: scan err: %s", err)
 		}
 
@@ -308,13 +346,14 @@ func (u *MachineReadableUi) Machine(category string, args ...string) {
 		category = category[commaIdx+1:]
 	}
 
-	// Prepare the args
+	// Prepare the args, without changing the caller's slice
+	escaped := make([]string, len(args))
 	for i, v := range args {
-		args[i] = strings.Replace(v, ",", "%!(PACKER_COMMA)", -1)
-		args[i] = strings.Replace(args[i], "\r", "\\r", -1)
-		args[i] = strings.Replace(args[i], "\n", "\\n", -1)
+		escaped[i] = strings.Replace(v, ",", "%!(PACKER_COMMA)", -1)
+		escaped[i] = strings.Replace(escaped[i], "\r", "\\r", -1)
+		escaped[i] = strings.Replace(escaped[i], "\n", "\\n", -1)
 	}
-	argsString := strings.Join(args, ",")
+	argsString := strings.Join(escaped, ",")
 
 	_, err := fmt.Fprintf(u.Writer, "%d,%s,%s,%s\n", now.Unix(), target, category, argsString)
 	if err != nil {
@@ -34,7 +34,7 @@ func testUi() *BasicUi {
 
 func TestColoredUi(t *testing.T) {
 	bufferUi := BufferUi()
-	ui := &ColoredUi{UiColorYellow, UiColorRed, bufferUi}
+	ui := &ColoredUi{Color: UiColorYellow, ErrorColor: UiColorRed, Ui: bufferUi}
 
 	if !ui.supportsColors() {
 		t.Skip("skipping for ui without color support")
@@ -66,7 +66,7 @@ func TestColoredUi(t *testing.T) {
 
 func TestColoredUi_noColorEnv(t *testing.T) {
 	bufferUi := testUi()
-	ui := &ColoredUi{UiColorYellow, UiColorRed, bufferUi}
+	ui := &ColoredUi{Color: UiColorYellow, ErrorColor: UiColorRed, Ui: bufferUi}
 
 	// Set the env var to get rid of the color
 	oldenv := os.Getenv("PACKER_NO_COLOR")
@@ -0,0 +1,276 @@
+package silent
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/alistanis/silentinstall/silent/ui"
+)
+
+// Var declares a variable that commands can use as {{.NAME}}, and what to do when it isn't set in the environment
+//
+//	"vars": {
+//	  "DB_HOST": {"description": "the database host", "default": "localhost"},
+//	  "DB_PASSWORD": {"description": "the database password", "secret": true, "prompt": true}
+//	}
+type Var struct {
+	Description string `json:"description"`
+	// Default is the value used when the variable isn't set. It's a pointer so that a default may be empty
+	Default *string `json:"default"`
+	// Secret variables are asked for without echoing them. Responses using them are kept out of logs and
+	// transcripts, and commands, connect targets and guards using them are shown with their values masked
+	Secret bool `json:"secret"`
+	// Prompt asks for the variable when it isn't set and the config is loaded interactively
+	Prompt bool `json:"prompt"`
+}
+
+// MissingVarsError lists every variable a config needs that has no value
+type MissingVarsError struct {
+	Names []string
+	// Vars are the config's declarations, for describing the missing variables
+	Vars map[string]*Var
+}
+
+func (e *MissingVarsError) Error() string {
+	described := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		described[i] = name
+		if v := e.Vars[name]; v != nil && v.Description != "" {
+			described[i] = fmt.Sprintf("%s (%s)", name, v.Description)
+		}
+	}
+	return "missing values for variables: " + strings.Join(described, ", ")
+}
+
+// LoadOption configures how a config's variables are resolved when it's loaded
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	vars map[string]string
+	ui   ui.Ui
+}
+
+// WithVars sets variables, taking precedence over the environment
+func WithVars(vars map[string]string) LoadOption {
+	return func(o *loadOptions) {
+		o.vars = vars
+	}
+}
+
+// WithPrompt asks u for variables declared with prompt that aren't set. Without it, they are missing like any
+// other variable without a value
+func WithPrompt(u ui.Ui) LoadOption {
+	return func(o *loadOptions) {
+		o.ui = u
+	}
+}
+
+// resolveVars returns the values of every variable cfg declares or its commands use, from o's vars, the
+// environment, prompting and defaults in that order. Every variable still without a value is returned in a
+// *MissingVarsError, before anything is asked
+func (cfg *Config) resolveVars(o *loadOptions) (map[string]string, error) {
+	values := envMap()
+	for k, v := range o.vars {
+		values[k] = v
+	}
+	names := cfg.usedVars()
+	for name := range cfg.Vars {
+		names[name] = true
+	}
+
+	var missing, ask []string
+	for name := range names {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		v := cfg.Vars[name]
+		switch {
+		case v != nil && v.Prompt && o.ui != nil:
+			ask = append(ask, name)
+		case v != nil && v.Default != nil:
+			values[name] = *v.Default
+		default:
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &MissingVarsError{Names: missing, Vars: cfg.Vars}
+	}
+
+	sort.Strings(ask)
+	for _, name := range ask {
+		value, err := cfg.Vars[name].ask(o.ui, name)
+		if err != nil {
+			return nil, fmt.Errorf("asking for %s: %s", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// ask asks u for the variable's value, using its default if the answer is empty
+func (v *Var) ask(u ui.Ui, name string) (string, error) {
+	query := name + ":"
+	if v.Description != "" {
+		query = fmt.Sprintf("%s (%s):", v.Description, name)
+	}
+	def := ""
+	var validators []ui.Validator
+	if v.Default != nil {
+		def = *v.Default
+	} else {
+		validators = append(validators, ui.NotEmpty)
+	}
+	if !v.Secret {
+		return ui.AskWithDefault(u, query, def, validators...)
+	}
+
+	// secret defaults aren't shown
+	if v.Default != nil {
+		query = strings.TrimSuffix(query, ":") + " (empty for the default):"
+	}
+	value, err := ui.AskSecret(u, query, validators...)
+	if err == nil && value == "" {
+		value = def
+	}
+	return value, err
+}
+
+// usedVars returns the names of the variables used by cfg's commands, hooks and global expectations
+func (cfg *Config) usedVars() map[string]bool {
+	names := make(map[string]bool)
+	for _, e := range cfg.GlobalExpectations {
+		addTemplateVars(names, e.Output)
+	}
+	var add func(cmds SilentCmds)
+	add = func(cmds SilentCmds) {
+		for _, c := range cmds {
+			for _, field := range c.templated() {
+				addTemplateVars(names, *field)
+			}
+			add(c.OnFailure)
+			add(c.OnSuccess)
+			add(c.Always)
+		}
+	}
+	for _, cmds := range []SilentCmds{cfg.Commands, cfg.OnFailure, cfg.OnSuccess, cfg.Always} {
+		add(cmds)
+	}
+	return names
+}
+
+// markSecrets marks expectations responding with a secret variable as secret themselves
+func (cfg *Config) markSecrets(expectations []*Expectation) {
+	secrets := cfg.secretVars()
+	for _, e := range expectations {
+		if usesVars(e.Output, secrets) {
+			e.Secret = true
+		}
+	}
+}
+
+// secretVars returns the names of the variables declared secret
+func (cfg *Config) secretVars() map[string]bool {
+	secrets := make(map[string]bool)
+	for name, v := range cfg.Vars {
+		if v != nil && v.Secret {
+			secrets[name] = true
+		}
+	}
+	return secrets
+}
+
+// usesVars returns true if the template text uses any of the variables in names
+func usesVars(text string, names map[string]bool) bool {
+	used := make(map[string]bool)
+	addTemplateVars(used, text)
+	for name := range used {
+		if names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// maskVars returns a copy of vars with the values of the variables in secrets masked
+func maskVars(vars map[string]string, secrets map[string]bool) map[string]string {
+	masked := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if secrets[k] {
+			v = secretMask
+		}
+		masked[k] = v
+	}
+	return masked
+}
+
+// addTemplateVars adds the variables used by the template text to names. Text that doesn't parse is left
+// as it is by ExecTemplate, so it uses no variables
+func addTemplateVars(names map[string]bool, text string) {
+	if !strings.Contains(text, "{{") {
+		return
+	}
+	t, err := template.New("vars").Parse(text)
+	if err != nil || t.Tree == nil {
+		return
+	}
+	addNodeVars(names, t.Tree.Root)
+}
+
+// addNodeVars adds the fields of dot used by node and its children to names. The bodies of with and range change
+// dot, so only their pipelines are looked at
+func addNodeVars(names map[string]bool, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			addNodeVars(names, child)
+		}
+	case *parse.ActionNode:
+		addNodeVars(names, n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			addNodeVars(names, cmd)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			addNodeVars(names, arg)
+		}
+	case *parse.ChainNode:
+		addNodeVars(names, n.Node)
+	case *parse.FieldNode:
+		names[n.Ident[0]] = true
+	case *parse.IfNode:
+		addNodeVars(names, n.Pipe)
+		addNodeVars(names, n.List)
+		addNodeVars(names, n.ElseList)
+	case *parse.WithNode:
+		addNodeVars(names, n.Pipe)
+	case *parse.RangeNode:
+		addNodeVars(names, n.Pipe)
+	case *parse.TemplateNode:
+		addNodeVars(names, n.Pipe)
+	}
+}
+
+// envMap returns the environment as a map of variables
+func envMap() map[string]string {
+	m := make(map[string]string)
+	for _, s := range os.Environ() {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		}
+	}
+	return m
+}
@@ -0,0 +1,169 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alistanis/silentinstall/silent/ui"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// answering returns a ui whose answers are read from answers
+func answering(answers string) *ui.BasicUi {
+	u := ui.BufferUi()
+	u.Reader = bytes.NewBufferString(answers)
+	return u
+}
+
+func TestNewConfigFromJSON_vars(t *testing.T) {
+	Convey("Every variable without a value is reported at once", t, func() {
+		_, err := NewConfigFromJSON([]byte(`{
+			"vars": {"DECLARED": {"description": "a declared variable"}, "ASKED": {"prompt": true}},
+			"commands": [{"cmd": "echo {{.SILENT_UNSET_B}} {{.SILENT_UNSET_A}}", "expectations": [{"input": "?", "output": "{{.DECLARED}}"}]}]
+		}`))
+		So(err, ShouldNotBeNil)
+		missing, ok := err.(*MissingVarsError)
+		So(ok, ShouldBeTrue)
+		So(missing.Names, ShouldResemble, []string{"ASKED", "DECLARED", "SILENT_UNSET_A", "SILENT_UNSET_B"})
+		So(err.Error(), ShouldEqual,
+			"missing values for variables: ASKED, DECLARED (a declared variable), SILENT_UNSET_A, SILENT_UNSET_B")
+	})
+
+	Convey("Variables come from the given vars, then the environment, then defaults", t, func() {
+		os.Setenv("SILENT_VARS_TEST", "env")
+		defer os.Unsetenv("SILENT_VARS_TEST")
+		data := []byte(`{
+			"vars": {"SILENT_VARS_TEST": {"default": "default"}, "EMPTY": {"default": ""}, "OTHER": {"default": "other"}},
+			"commands": [{"cmd": "echo {{.SILENT_VARS_TEST}} {{.OTHER}}{{.EMPTY}}"}]
+		}`)
+		cfg, err := NewConfigFromJSON(data)
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].CmdString, ShouldEqual, "echo env other")
+
+		cfg, err = NewConfigFromJSON(data, WithVars(map[string]string{"SILENT_VARS_TEST": "given"}))
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].CmdString, ShouldEqual, "echo given other")
+	})
+
+	Convey("Environment values containing = are kept whole", t, func() {
+		os.Setenv("SILENT_VARS_TEST", "a=b")
+		defer os.Unsetenv("SILENT_VARS_TEST")
+		cfg, err := NewConfigFromJSON([]byte(`[{"cmd": "echo {{.SILENT_VARS_TEST}}"}]`))
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].CmdString, ShouldEqual, "echo a=b")
+	})
+
+	Convey("When prompting, variables declared with prompt are asked for", t, func() {
+		u := answering("\nbob\n\nsecret\n")
+		cfg, err := NewConfigFromJSON([]byte(`{
+			"vars": {
+				"A_USER": {"description": "the user", "prompt": true},
+				"B_HOST": {"prompt": true, "default": "localhost"},
+				"C_PASSWORD": {"prompt": true, "secret": true}
+			},
+			"commands": [{"cmd": "echo {{.A_USER}}@{{.B_HOST}}", "expectations": [{"input": "?", "output": "{{.C_PASSWORD}}"}]}]
+		}`), WithPrompt(u))
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].CmdString, ShouldEqual, "echo bob@localhost")
+		So(cfg.Commands[0].Expectations[0].Output, ShouldEqual, "secret")
+		So(cfg.Commands[0].Expectations[0].Secret, ShouldBeTrue)
+
+		written := u.Writer.(*bytes.Buffer).String()
+		So(written, ShouldContainSubstring, "the user (A_USER):")
+		So(written, ShouldContainSubstring, "B_HOST: [localhost]")
+		So(written, ShouldContainSubstring, "C_PASSWORD:")
+		So(written, ShouldNotContainSubstring, "secret")
+		// the empty answer for A_USER was asked again
+		So(u.ErrorWriter.(*bytes.Buffer).String(), ShouldContainSubstring, "an answer is required")
+	})
+
+	Convey("Nothing is asked while other variables are missing", t, func() {
+		u := answering("bob\n")
+		_, err := NewConfigFromJSON([]byte(`{
+			"vars": {"A_USER": {"prompt": true}},
+			"commands": [{"cmd": "echo {{.A_USER}} {{.SILENT_UNSET}}"}]
+		}`), WithPrompt(u))
+		So(err, ShouldNotBeNil)
+		So(err.(*MissingVarsError).Names, ShouldResemble, []string{"SILENT_UNSET"})
+		So(u.Writer.(*bytes.Buffer).String(), ShouldBeEmpty)
+	})
+
+	Convey("Secret variables answer prompts without being logged", t, func() {
+		transcript := &bytes.Buffer{}
+		cfg, err := NewConfigFromJSON([]byte(`{
+			"vars": {"PASSWORD": {"secret": true}},
+			"global_expectations": [{"input": "Password for", "output": "{{.PASSWORD}}"}],
+			"commands": [{"cmd": "{{.GOPATH}}`+testDataPath+`/password.sh"}]
+		}`), WithVars(map[string]string{"PASSWORD": "hunter2"}))
+		So(err, ShouldBeNil)
+		So(cfg.GlobalExpectations[0].Secret, ShouldBeTrue)
+		So(cfg.Commands.Exec(WithTranscript(transcript)), ShouldEqual, io.EOF)
+		So(cfg.Commands[0].Output(), ShouldEqual, "Password for bob: got hunter2\n")
+		// password.sh echoes the password itself, but the response is masked
+		So(transcript.String(), ShouldStartWith, "Password for bob: "+secretMask+"\n")
+	})
+
+	Convey("Commands and guards using secret variables are shown masked", t, func() {
+		dir, err := ioutil.TempDir("", "silent-vars")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		cfg, err := NewConfigFromJSON([]byte(`{
+			"vars": {"PW": {"secret": true, "default": "hunter2"}},
+			"commands": [
+				{"cmd": "true {{.PW}}", "unless": "test {{.PW}} = x"},
+				{"cmd": "test {{.PW}} = x", "ignore_errors": true}
+			]
+		}`))
+		So(err, ShouldBeNil)
+		So(cfg.Commands[0].CmdString, ShouldEqual, "true hunter2")
+		So(cfg.Commands[0].DisplayName(), ShouldEqual, "true "+secretMask)
+
+		st, err := LoadState(filepath.Join(dir, "state"))
+		So(err, ShouldBeNil)
+		log, events := &bytes.Buffer{}, &bytes.Buffer{}
+		r := cfg.Runner()
+		r.State = st
+		So(r.Run(WithLogger(NewLogger(log, LevelTrace, LogText)), WithEventSink(NewJSONLSink(events))),
+			ShouldEqual, io.EOF)
+
+		So(r.Results[1].Cmd, ShouldEqual, "test "+secretMask+" = x")
+		So(r.Results[1].Environ(), ShouldContain, "SILENT_CMD=test "+secretMask+" = x")
+		report, err := json.Marshal(r.Report())
+		So(err, ShouldBeNil)
+		state, err := ioutil.ReadFile(filepath.Join(dir, "state"))
+		So(err, ShouldBeNil)
+		for _, shown := range []string{log.String(), events.String(), string(report), r.Report().Table(), string(state)} {
+			So(shown, ShouldContainSubstring, secretMask)
+			So(shown, ShouldNotContainSubstring, "hunter2")
+		}
+	})
+
+	Convey("Guards using secret variables are shown masked when they skip a command", t, func() {
+		cfg, err := NewConfigFromJSON([]byte(`{
+			"vars": {"PW": {"secret": true}},
+			"commands": [{"name": "a", "cmd": "true", "only_if": "test {{.PW}} = x"}]
+		}`), WithVars(map[string]string{"PW": "hunter2"}))
+		So(err, ShouldBeNil)
+		skip, err := cfg.Commands[0].CheckGuards()
+		So(err, ShouldBeNil)
+		So(skip, ShouldEqual, `only_if check "test `+secretMask+` = x" failed`)
+	})
+}
+
+func TestAddTemplateVars(t *testing.T) {
+	Convey("Variables are found throughout templates", t, func() {
+		names := make(map[string]bool)
+		addTemplateVars(names, `{{if .A}}{{.B}}{{else}}{{.C | printf "%s"}}{{end}} {{with .D}}{{.NotAVar}}{{end}}`)
+		addTemplateVars(names, `{{range .E}}{{.NotAVar}}{{end}} {{printf "%s-%s" .F .G.H}}`)
+		addTemplateVars(names, `no templates here, {{ or this`)
+		So(names, ShouldResemble, map[string]bool{
+			"A": true, "B": true, "C": true, "D": true, "E": true, "F": true, "G": true,
+		})
+	})
+}